@@ -0,0 +1,666 @@
+package confix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrCodecRegistered is returned by RegisterTypeCodec when the type already has a codec.
+var ErrCodecRegistered = errors.New("codec already registered")
+
+// typeCodec converts values of a type to and from their representation in configuration files.
+type typeCodec struct {
+	t reflect.Type
+	// marshal returns the external representation of a value of the type
+	marshal func(v any) (any, error)
+	// unmarshal returns a value of the type from its external representation
+	unmarshal func(data any) (any, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	// typeCodecs maps types to their codecs
+	typeCodecs = map[reflect.Type]typeCodec{}
+)
+
+// RegisterTypeCodec registers the conversion of values of the type t to and from their
+// representation in configuration files, e.g. an enum written as a string.
+// Fields of the type, and pointers, slices and maps of it, are converted in JSON, YAML, TOML
+// and INI files, defaults and environment variables, and when configuration files are written.
+//
+// marshal receives a value of the type t and returns the value to write, e.g. a string.
+// unmarshal receives the value decoded from the file, i.e. a string, bool, int64, float64,
+// []any or map[string]any, and returns a value of the type t; in INI files, defaults and
+// environment variables the value is always a string.
+// When a struct with such fields is written as JSON or TOML, keys are written in alphabetical order.
+//
+// It returns ErrCodecRegistered if t already has a codec.
+// RegisterTypeCodec is safe to call from init functions and concurrently.
+func RegisterTypeCodec(t reflect.Type, marshal func(v any) (any, error), unmarshal func(data any) (any, error)) error {
+	if t == nil || marshal == nil || unmarshal == nil {
+		return errors.New("invalid type codec")
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	if _, ok := typeCodecs[t]; ok {
+		return fmt.Errorf("%w: %s", ErrCodecRegistered, t)
+	}
+	typeCodecs[t] = typeCodec{t: t, marshal: marshal, unmarshal: unmarshal}
+	return nil
+}
+
+// lookupTypeCodec returns the codec registered for the type t.
+func lookupTypeCodec(t reflect.Type) (typeCodec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := typeCodecs[t]
+	return c, ok
+}
+
+// hasTypeCodec reports whether a value of the type t can hold a value of a type with a codec.
+func hasTypeCodec(t reflect.Type) bool {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	if len(typeCodecs) == 0 || t == nil {
+		return false
+	}
+	return containsCodecType(t, map[reflect.Type]bool{})
+}
+
+// containsCodecType reports whether t, or a type of the fields or elements of t, has a codec.
+// seen holds the types already visited to stop at recursive types.
+func containsCodecType(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if _, ok := typeCodecs[t]; ok {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); (f.IsExported() || f.Anonymous) && containsCodecType(f.Type, seen) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return containsCodecType(t.Elem(), seen)
+	}
+	return false
+}
+
+// decode returns the value of the codec type for data, failing if unmarshal returns another type.
+func (c typeCodec) decode(data any) (reflect.Value, error) {
+	v, err := c.unmarshal(normalizeCodecData(data))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Type() != c.t {
+		return reflect.Value{}, fmt.Errorf("codec of %s returned %T", c.t, v)
+	}
+	return rv, nil
+}
+
+// normalizeCodecData returns data with the numbers of the decoders turned into int64 and float64,
+// so that codecs get the same values from every format.
+func normalizeCodecData(data any) any {
+	switch d := data.(type) {
+	case json.Number:
+		if i, err := d.Int64(); err == nil {
+			return i
+		}
+		if f, err := d.Float64(); err == nil {
+			return f
+		}
+	case int:
+		return int64(d)
+	case []any:
+		for i, e := range d {
+			d[i] = normalizeCodecData(e)
+		}
+	case []map[string]any:
+		s := make([]any, len(d))
+		for i, e := range d {
+			s[i] = normalizeCodecData(e)
+		}
+		return s
+	case map[string]any:
+		for k, e := range d {
+			d[k] = normalizeCodecData(e)
+		}
+	}
+	return data
+}
+
+// codecStep leads from a value to a nested one: to the field named field of a struct,
+// the element index of a slice or an array, or the entry key of a map.
+type codecStep struct {
+	field string
+	index int
+	key   string
+}
+
+// codecValue is a value returned by a codec together with the steps leading to the field
+// it belongs to from the decoded value.
+type codecValue struct {
+	steps []codecStep
+	v     reflect.Value
+}
+
+// withStep returns steps followed by step in a new slice.
+func withStep(steps []codecStep, step codecStep) []codecStep {
+	return append(steps[:len(steps):len(steps)], step)
+}
+
+// assignCodecValues stores the values returned by codecs in the fields of v they belong to.
+// The format decoders only see encoded copies of these values, which lose the state of types
+// with unexported fields.
+func assignCodecValues(v any, values []codecValue) {
+	for _, cv := range values {
+		setCodecValue(reflect.ValueOf(v), cv.steps, cv.v)
+	}
+}
+
+// setCodecValue stores x in the value found in v by following the steps, allocating nil pointers
+// on the way. Values the steps don't lead to are skipped.
+func setCodecValue(v reflect.Value, steps []codecStep, x reflect.Value) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			if !v.CanSet() {
+				return
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(steps) == 0 {
+		if v.CanSet() && x.Type().AssignableTo(v.Type()) {
+			v.Set(x)
+		}
+		return
+	}
+
+	step := steps[0]
+	switch v.Kind() {
+	case reflect.Struct:
+		f, ok := v.Type().FieldByName(step.field)
+		if !ok {
+			return
+		}
+		if fv, err := v.FieldByIndexErr(f.Index); err == nil {
+			setCodecValue(fv, steps[1:], x)
+		}
+	case reflect.Slice, reflect.Array:
+		if step.index < v.Len() {
+			setCodecValue(v.Index(step.index), steps[1:], x)
+		}
+	case reflect.Map:
+		kt := v.Type().Key()
+		if v.IsNil() || kt.Kind() != reflect.String {
+			return
+		}
+		k := reflect.ValueOf(step.key).Convert(kt)
+		e := reflect.New(v.Type().Elem()).Elem()
+		if cur := v.MapIndex(k); cur.IsValid() {
+			e.Set(cur)
+		}
+		setCodecValue(e, steps[1:], x)
+		v.SetMapIndex(k, e)
+	}
+}
+
+// unmarshalCodecs returns data decoded into generic values with the values destined for types
+// with codecs of the type t replaced by the results of the codecs, so that the data can be encoded
+// and decoded into t again. The results are also appended to values, with steps leading to them,
+// to be assigned once the data is decoded. lookup matches keys to struct fields, path names the value in errors.
+func unmarshalCodecs(data any, t reflect.Type, lookup func(reflect.Type, string) (reflect.StructField, bool), path string,
+	steps []codecStep, values *[]codecValue) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if c, ok := lookupTypeCodec(t); ok {
+		v, err := c.decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding field %s: %w", path, err)
+		}
+		*values = append(*values, codecValue{steps: steps, v: v})
+		return v.Interface(), nil
+	}
+
+	switch d := data.(type) {
+	case []any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return data, nil
+		}
+		for i, e := range d {
+			c, err := unmarshalCodecs(e, t.Elem(), lookup, fmt.Sprintf("%s[%d]", path, i), withStep(steps, codecStep{index: i}), values)
+			if err != nil {
+				return nil, err
+			}
+			d[i] = c
+		}
+	case []map[string]any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return data, nil
+		}
+		s := make([]any, len(d))
+		for i, e := range d {
+			c, err := unmarshalCodecs(e, t.Elem(), lookup, fmt.Sprintf("%s[%d]", path, i), withStep(steps, codecStep{index: i}), values)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = c
+		}
+		return s, nil
+	case map[string]any:
+		for k, e := range d {
+			var et reflect.Type
+			step := codecStep{key: k}
+			switch t.Kind() {
+			case reflect.Map:
+				et = t.Elem()
+			case reflect.Struct:
+				f, ok := lookup(t, k)
+				if !ok {
+					continue
+				}
+				et, step = f.Type, codecStep{field: f.Name}
+			default:
+				return data, nil
+			}
+
+			c, err := unmarshalCodecs(e, et, lookup, joinPath(path, k), withStep(steps, step), values)
+			if err != nil {
+				return nil, err
+			}
+			d[k] = c
+		}
+	}
+	return data, nil
+}
+
+// marshalCodecs returns data, the value v encoded and decoded into generic values,
+// with the values of types with codecs replaced by the results of the codecs.
+// lookup matches keys to struct fields, path names the value in errors.
+func marshalCodecs(data any, v reflect.Value, lookup func(reflect.Type, string) (reflect.StructField, bool), path string) (any, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return data, nil
+		}
+		v = v.Elem()
+	}
+	if c, ok := lookupTypeCodec(v.Type()); ok {
+		d, err := c.marshal(v.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("error while encoding field %s: %w", path, err)
+		}
+		return d, nil
+	}
+
+	switch d := data.(type) {
+	case []any:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return data, nil
+		}
+		for i := 0; i < len(d) && i < v.Len(); i++ {
+			c, err := marshalCodecs(d[i], v.Index(i), lookup, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			d[i] = c
+		}
+	case []map[string]any:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return data, nil
+		}
+		s := make([]any, len(d))
+		for i := range d {
+			s[i] = any(d[i])
+			if i >= v.Len() {
+				continue
+			}
+			c, err := marshalCodecs(d[i], v.Index(i), lookup, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = c
+		}
+		return s, nil
+	case map[string]any:
+		for k, e := range d {
+			fv := codecField(v, k, lookup)
+			if !fv.IsValid() {
+				continue
+			}
+			c, err := marshalCodecs(e, fv, lookup, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			d[k] = c
+		}
+	}
+	return data, nil
+}
+
+// codecField returns the value of the struct field or the map entry of v encoded under the key,
+// or the zero Value when there is none.
+func codecField(v reflect.Value, key string, lookup func(reflect.Type, string) (reflect.StructField, bool)) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		f, ok := lookup(v.Type(), key)
+		if !ok {
+			return reflect.Value{}
+		}
+		return v.FieldByName(f.Name)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+	}
+	return reflect.Value{}
+}
+
+// joinPath returns the key nested under path in the dotted form used in errors.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unmarshalJSONCodecs reads JSON data from r and returns a reader of the same data in which
+// values for fields of v with codecs are replaced by the results of the codecs, and the results
+// to assign with assignCodecValues once the data is decoded.
+func unmarshalJSONCodecs(r io.Reader, v any) (io.Reader, []codecValue, error) {
+	var data any
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, nil, err
+	}
+
+	var values []codecValue
+	data, err := unmarshalCodecs(data, reflect.TypeOf(v), lookupJSONField, "", nil, &values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bytes.NewReader(b), values, nil
+}
+
+// unmarshalTOMLCodecs reads TOML data from r and returns a reader of the same data in which
+// values for fields of v with codecs are replaced by the results of the codecs, and the results
+// to assign with assignCodecValues once the data is decoded.
+func unmarshalTOMLCodecs(r io.Reader, v any) (io.Reader, []codecValue, error) {
+	data := map[string]any{}
+	if _, err := toml.NewDecoder(r).Decode(&data); err != nil {
+		return nil, nil, err
+	}
+
+	var values []codecValue
+	if _, err := unmarshalCodecs(data, reflect.TypeOf(v), lookupTOMLField, "", nil, &values); err != nil {
+		return nil, nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(data); err != nil {
+		return nil, nil, err
+	}
+	return buf, values, nil
+}
+
+// unmarshalYAMLCodecs reads YAML data from r and returns a reader of the same data in which
+// values for fields of v with codecs are replaced by the results of the codecs, and the results
+// to assign with assignCodecValues once the data is decoded.
+func unmarshalYAMLCodecs(r io.Reader, v any) (io.Reader, []codecValue, error) {
+	var n yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&n); err != nil {
+		if errors.Is(err, io.EOF) {
+			return bytes.NewReader(nil), nil, nil
+		}
+		return nil, nil, err
+	}
+	var values []codecValue
+	if err := unmarshalYAMLNode(&n, reflect.TypeOf(v), "", nil, &values); err != nil {
+		return nil, nil, err
+	}
+
+	b, err := yaml.Marshal(&n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bytes.NewReader(b), values, nil
+}
+
+// unmarshalYAMLNode replaces the nodes of n destined for types with codecs of the type t
+// by the encoded results of the codecs and appends the results to values like unmarshalCodecs.
+// path names the value in errors.
+func unmarshalYAMLNode(n *yaml.Node, t reflect.Type, path string, steps []codecStep, values *[]codecValue) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if c, ok := lookupTypeCodec(t); ok && n.Kind != yaml.DocumentNode {
+		var data any
+		if err := n.Decode(&data); err != nil {
+			return fmt.Errorf("error while decoding field %s: %w", path, err)
+		}
+		v, err := c.decode(data)
+		if err != nil {
+			return fmt.Errorf("error while decoding field %s: %w", path, err)
+		}
+		*values = append(*values, codecValue{steps: steps, v: v})
+		return n.Encode(v.Interface())
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if err := unmarshalYAMLNode(c, t, path, steps, values); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return nil
+		}
+		for i, c := range n.Content {
+			if err := unmarshalYAMLNode(c, t.Elem(), fmt.Sprintf("%s[%d]", path, i), withStep(steps, codecStep{index: i}), values); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			var et reflect.Type
+			step := codecStep{key: k}
+			switch t.Kind() {
+			case reflect.Map:
+				et = t.Elem()
+			case reflect.Struct:
+				f, ok := lookupYAMLField(t, k)
+				if !ok {
+					continue
+				}
+				et, step = f.Type, codecStep{field: f.Name}
+			default:
+				return nil
+			}
+			if err := unmarshalYAMLNode(n.Content[i+1], et, joinPath(path, k), withStep(steps, step), values); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// marshalYAMLNode replaces the nodes of n, the node encoded from v, holding values of types
+// with codecs by the encoded results of the codecs. path names the value in errors.
+func marshalYAMLNode(n *yaml.Node, v reflect.Value, path string) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if c, ok := lookupTypeCodec(v.Type()); ok && n.Kind != yaml.DocumentNode {
+		data, err := c.marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("error while encoding field %s: %w", path, err)
+		}
+		return n.Encode(data)
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if err := marshalYAMLNode(c, v, path); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil
+		}
+		for i := 0; i < len(n.Content) && i < v.Len(); i++ {
+			if err := marshalYAMLNode(n.Content[i], v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			fv := codecField(v, k, lookupYAMLField)
+			if !fv.IsValid() {
+				continue
+			}
+			if err := marshalYAMLNode(n.Content[i+1], fv, joinPath(path, k)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeWithCodecs writes v with the encoder e of the format of the extension ext, with values
+// of types with codecs converted to their external representation for the built-in formats
+// except INI, whose encoder converts them itself.
+func encodeWithCodecs(e encoder, ext string, v any, opts EncoderOptions) error {
+	if !hasTypeCodec(reflect.TypeOf(v)) {
+		return e.Encode(v)
+	}
+
+	f, _ := lookupFormat(ext)
+	switch f.name {
+	case "json":
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var data any
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err = dec.Decode(&data); err != nil {
+			return err
+		}
+		if data, err = marshalCodecs(data, reflect.ValueOf(v), lookupJSONField, ""); err != nil {
+			return err
+		}
+		return e.Encode(data)
+	case "toml":
+		buf := &bytes.Buffer{}
+		if err := toml.NewEncoder(buf).Encode(v); err != nil {
+			return err
+		}
+		data := map[string]any{}
+		if _, err := toml.NewDecoder(buf).Decode(&data); err != nil {
+			return err
+		}
+		if _, err := marshalCodecs(data, reflect.ValueOf(v), lookupTOMLField, ""); err != nil {
+			return err
+		}
+		return e.Encode(data)
+	case "yaml":
+		node, err := encodeYAMLNode(v, opts)
+		if err != nil {
+			return err
+		}
+		return e.Encode(node)
+	default:
+		return e.Encode(v)
+	}
+}
+
+// encodeYAMLNode returns v encoded as a YAML node with values of types with codecs converted
+// and, with the YAMLComments option, the comment tags of the fields added. Nodes are returned as is.
+func encodeYAMLNode(v any, opts EncoderOptions) (*yaml.Node, error) {
+	if n, ok := v.(*yaml.Node); ok {
+		return n, nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	if hasTypeCodec(reflect.TypeOf(v)) {
+		if err := marshalYAMLNode(node, reflect.ValueOf(v), ""); err != nil {
+			return nil, err
+		}
+	}
+	if opts.YAMLComments {
+		addYAMLComments(node, reflect.TypeOf(v))
+	}
+	return node, nil
+}
+
+// lookupTOMLField returns the field of the struct type t that the TOML decoder decodes the key into,
+// preferring an exact match of the name over a case-insensitive one.
+func lookupTOMLField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if ef, ok := lookupTOMLField(f.Type, key); ok {
+				return ef, true
+			}
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			fold, found = f, true
+		}
+	}
+	return fold, found
+}
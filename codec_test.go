@@ -0,0 +1,211 @@
+package confix
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecLevel int
+
+const (
+	codecLevelDebug codecLevel = iota
+	codecLevelInfo
+	codecLevelError
+)
+
+var codecLevelNames = []string{"debug", "info", "error"}
+
+type codecConfig struct {
+	Level  codecLevel            `json:"level" yaml:"level" toml:"level" config:"level" default:"info"`
+	Levels []codecLevel          `json:"levels" yaml:"levels" toml:"levels" config:"levels"`
+	ByName map[string]codecLevel `json:"by_name" yaml:"by_name" toml:"by_name" config:"-"`
+	Ptr    *codecLevel           `json:"ptr,omitempty" yaml:"ptr,omitempty" toml:"ptr,omitempty" config:"-"`
+	Name   string                `json:"name" yaml:"name" toml:"name" config:"name"`
+}
+
+// registerCodecLevel registers the codec of codecLevel for the duration of the test.
+func registerCodecLevel(t *testing.T) {
+	t.Helper()
+	err := RegisterTypeCodec(reflect.TypeOf(codecLevel(0)),
+		func(v any) (any, error) {
+			l := v.(codecLevel)
+			if int(l) >= len(codecLevelNames) {
+				return nil, fmt.Errorf("unknown level %d", l)
+			}
+			return codecLevelNames[l], nil
+		},
+		func(data any) (any, error) {
+			s, ok := data.(string)
+			if !ok {
+				return nil, fmt.Errorf("level must be a string, got %T", data)
+			}
+			for i, name := range codecLevelNames {
+				if name == s {
+					return codecLevel(i), nil
+				}
+			}
+			return nil, fmt.Errorf("unknown level %q", s)
+		})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		codecsMu.Lock()
+		defer codecsMu.Unlock()
+		delete(typeCodecs, reflect.TypeOf(codecLevel(0)))
+	})
+}
+
+func TestRegisterTypeCodec(t *testing.T) {
+	registerCodecLevel(t)
+
+	t.Run("negative: registered", func(t *testing.T) {
+		err := RegisterTypeCodec(reflect.TypeOf(codecLevel(0)),
+			func(v any) (any, error) { return v, nil },
+			func(data any) (any, error) { return data, nil })
+		assert.ErrorIs(t, err, ErrCodecRegistered)
+	})
+	t.Run("negative: invalid", func(t *testing.T) {
+		assert.Error(t, RegisterTypeCodec(nil, nil, nil))
+	})
+}
+
+func TestTypeCodecDecode(t *testing.T) {
+	registerCodecLevel(t)
+	errorLevel := codecLevelError
+
+	for ext, data := range map[string]string{
+		".json": `{"level": "error", "levels": ["debug", "info"], "by_name": {"a": "error"}, "ptr": "error", "name": "x"}`,
+		".yaml": "level: error\nlevels: [debug, info]\nby_name:\n  a: error\nptr: error\nname: x\n",
+		".toml": "level = \"error\"\nlevels = [\"debug\", \"info\"]\nptr = \"error\"\nname = \"x\"\n[by_name]\na = \"error\"\n",
+	} {
+		t.Run(ext, func(t *testing.T) {
+			cfg := new(codecConfig)
+			require.NoError(t, Unmarshal([]byte(data), ext, cfg, WithStrictDecoding[codecConfig]()))
+			assert.Equal(t, &codecConfig{
+				Level:  codecLevelError,
+				Levels: []codecLevel{codecLevelDebug, codecLevelInfo},
+				ByName: map[string]codecLevel{"a": codecLevelError},
+				Ptr:    &errorLevel,
+				Name:   "x",
+			}, cfg)
+		})
+	}
+	t.Run(".ini", func(t *testing.T) {
+		cfg := new(codecConfig)
+		require.NoError(t, Unmarshal([]byte("level = error\nlevels = debug, info\n"), ".ini", cfg))
+		assert.Equal(t, codecLevelError, cfg.Level)
+		assert.Equal(t, []codecLevel{codecLevelDebug, codecLevelInfo}, cfg.Levels)
+	})
+	t.Run("defaults", func(t *testing.T) {
+		cfg := new(codecConfig)
+		require.NoError(t, applyDefaults(reflect.ValueOf(cfg).Elem()))
+		assert.Equal(t, codecLevelInfo, cfg.Level)
+	})
+	t.Run("negative: unknown value", func(t *testing.T) {
+		err := Unmarshal([]byte("levels: [debug, trace]\n"), ".yaml", new(codecConfig))
+		assert.ErrorContains(t, err, "levels[1]")
+		assert.ErrorContains(t, err, `unknown level "trace"`)
+	})
+	t.Run("negative: wrong type", func(t *testing.T) {
+		err := Unmarshal([]byte(`{"level": 2}`), ".json", new(codecConfig))
+		assert.ErrorContains(t, err, "level must be a string, got int64")
+	})
+}
+
+// codecOpaque holds only unexported state, which the format decoders can't set.
+type codecOpaque struct{ v string }
+
+type opaqueConfig struct {
+	Value  codecOpaque            `json:"value" yaml:"value" toml:"value"`
+	List   []codecOpaque          `json:"list" yaml:"list" toml:"list"`
+	ByName map[string]codecOpaque `json:"by_name" yaml:"by_name" toml:"by_name"`
+	Ptr    *codecOpaque           `json:"ptr" yaml:"ptr" toml:"ptr"`
+}
+
+func TestTypeCodecOpaque(t *testing.T) {
+	require.NoError(t, RegisterTypeCodec(reflect.TypeOf(codecOpaque{}),
+		func(v any) (any, error) { return v.(codecOpaque).v, nil },
+		func(data any) (any, error) { return codecOpaque{v: fmt.Sprint(data)}, nil }))
+	t.Cleanup(func() {
+		codecsMu.Lock()
+		defer codecsMu.Unlock()
+		delete(typeCodecs, reflect.TypeOf(codecOpaque{}))
+	})
+
+	for ext, data := range map[string]string{
+		".json": `{"value": "a", "list": ["b", "c"], "by_name": {"x": "d"}, "ptr": "e"}`,
+		".yaml": "value: a\nlist: [b, c]\nby_name:\n  x: d\nptr: e\n",
+		".toml": "value = \"a\"\nlist = [\"b\", \"c\"]\nptr = \"e\"\n[by_name]\nx = \"d\"\n",
+	} {
+		t.Run(ext, func(t *testing.T) {
+			cfg := new(opaqueConfig)
+			require.NoError(t, Unmarshal([]byte(data), ext, cfg, WithStrictDecoding[opaqueConfig]()))
+			assert.Equal(t, &opaqueConfig{
+				Value:  codecOpaque{v: "a"},
+				List:   []codecOpaque{{v: "b"}, {v: "c"}},
+				ByName: map[string]codecOpaque{"x": {v: "d"}},
+				Ptr:    &codecOpaque{v: "e"},
+			}, cfg)
+		})
+	}
+}
+
+func TestTypeCodecEncode(t *testing.T) {
+	registerCodecLevel(t)
+	cfg := &codecConfig{
+		Level:  codecLevelError,
+		Levels: []codecLevel{codecLevelDebug},
+		ByName: map[string]codecLevel{"a": codecLevelInfo},
+		Name:   "x",
+	}
+
+	for ext, expected := range map[string]string{
+		".json": "{\n  \"by_name\": {\n    \"a\": \"info\"\n  },\n  \"level\": \"error\",\n  \"levels\": [\n    \"debug\"\n  ],\n  \"name\": \"x\"\n}\n",
+		".yaml": "level: error\nlevels:\n  - debug\nby_name:\n  a: info\nname: x\n",
+		".toml": "level = \"error\"\nlevels = [\"debug\"]\nname = \"x\"\n\n[by_name]\n  a = \"info\"\n",
+	} {
+		t.Run(ext, func(t *testing.T) {
+			data, err := Marshal(cfg, ext)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(data))
+		})
+	}
+
+	t.Run(".ini", func(t *testing.T) {
+		data, err := Marshal(&struct {
+			Level codecLevel `config:"level"`
+			Name  string     `config:"name"`
+		}{Level: codecLevelError, Name: "x"}, ".ini")
+		require.NoError(t, err)
+		assert.Equal(t, "level = error\nname = x\n", string(data))
+	})
+	t.Run("round trip", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "config.yaml")
+		c := &config[codecConfig]{cfg: cfg}
+		require.NoError(t, c.writeToFile(fpath))
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		loaded := new(codecConfig)
+		require.NoError(t, Unmarshal(data, ".yaml", loaded))
+		assert.Equal(t, cfg, loaded)
+	})
+	t.Run("negative: marshal error", func(t *testing.T) {
+		_, err := Marshal(&codecConfig{Level: 7}, ".json")
+		assert.ErrorContains(t, err, "unknown level 7")
+	})
+}
+
+func TestTypeCodecEnv(t *testing.T) {
+	registerCodecLevel(t)
+	t.Setenv("APP_LEVEL", "debug")
+
+	cfg := &codecConfig{Level: codecLevelError}
+	require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "APP"))
+	assert.Equal(t, codecLevelDebug, cfg.Level)
+}
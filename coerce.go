@@ -0,0 +1,268 @@
+package confix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+// timeLayouts are the forms of datetimes accepted for time.Time fields with coercion,
+// the same as TOML datetimes: with an offset, local datetimes and local dates.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+}
+
+// parseTime parses s in one of timeLayouts. Datetimes and dates without an offset are read as UTC,
+// as YAML timestamps are.
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("parsing time %q: not a RFC 3339 datetime, local datetime or date", s)
+}
+
+// coerceJSON reads JSON data from r and returns a reader of the same data in which strings
+// given for time.Duration and ByteSize fields of v are replaced by numbers and strings
+// given for time.Time fields by RFC 3339 datetimes.
+func coerceJSON(r io.Reader, v any) (io.Reader, error) {
+	var data any
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	data, err := coerceJSONValue(data, reflect.TypeOf(v), "")
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// coerceJSONValue returns data decoded from JSON with strings for time.Duration and ByteSize
+// values of the type t replaced by numbers and strings for time.Time values normalized.
+// path names the value in errors.
+func coerceJSONValue(data any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch d := data.(type) {
+	case string:
+		switch t {
+		case durationType:
+			dur, err := time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing field %s: %w", path, err)
+			}
+			return json.Number(strconv.FormatInt(int64(dur), 10)), nil
+		case byteSizeType:
+			size, err := ParseByteSize(d)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing field %s: %w", path, err)
+			}
+			return json.Number(strconv.FormatUint(uint64(size), 10)), nil
+		case timeType:
+			tm, err := parseTime(d)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing field %s: %w", path, err)
+			}
+			return tm.Format(time.RFC3339Nano), nil
+		}
+	case []any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return data, nil
+		}
+		for i, e := range d {
+			c, err := coerceJSONValue(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			d[i] = c
+		}
+	case map[string]any:
+		for k, e := range d {
+			var et reflect.Type
+			switch t.Kind() {
+			case reflect.Map:
+				et = t.Elem()
+			case reflect.Struct:
+				f, ok := lookupJSONField(t, k)
+				if !ok {
+					continue
+				}
+				et = f.Type
+			default:
+				return data, nil
+			}
+
+			key := k
+			if path != "" {
+				key = path + "." + k
+			}
+			c, err := coerceJSONValue(e, et, key)
+			if err != nil {
+				return nil, err
+			}
+			d[k] = c
+		}
+	}
+	return data, nil
+}
+
+// lookupJSONField returns the field of the struct type t that encoding/json decodes the key into,
+// preferring an exact match of the name over a case-insensitive one.
+func lookupJSONField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if ef, ok := lookupJSONField(f.Type, key); ok {
+				return ef, true
+			}
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			fold, found = f, true
+		}
+	}
+	return fold, found
+}
+
+// coerceYAML reads YAML data from r and returns a reader of the same data in which quoted
+// strings given for time.Time fields of v are replaced by timestamps.
+func coerceYAML(r io.Reader, v any) (io.Reader, error) {
+	var n yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&n); err != nil {
+		if errors.Is(err, io.EOF) {
+			return bytes.NewReader(nil), nil
+		}
+		return nil, err
+	}
+	if err := coerceYAMLNode(&n, reflect.TypeOf(v), ""); err != nil {
+		return nil, err
+	}
+
+	b, err := yaml.Marshal(&n)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// coerceYAMLNode replaces string scalars of n destined for time.Time values of the type t
+// by timestamps. path names the value in errors.
+func coerceYAMLNode(n *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if err := coerceYAMLNode(c, t, path); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if t != timeType || n.ShortTag() != "!!str" {
+			return nil
+		}
+		tm, err := parseTime(n.Value)
+		if err != nil {
+			return fmt.Errorf("error while parsing field %s: %w", path, err)
+		}
+		n.Value, n.Tag, n.Style = tm.Format(time.RFC3339Nano), "!!timestamp", 0
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return nil
+		}
+		for i, c := range n.Content {
+			if err := coerceYAMLNode(c, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			var et reflect.Type
+			switch t.Kind() {
+			case reflect.Map:
+				et = t.Elem()
+			case reflect.Struct:
+				f, ok := lookupYAMLField(t, k)
+				if !ok {
+					continue
+				}
+				et = f.Type
+			default:
+				return nil
+			}
+
+			key := k
+			if path != "" {
+				key = path + "." + k
+			}
+			if err := coerceYAMLNode(n.Content[i+1], et, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupYAMLField returns the field of the struct type t that yaml.v3 decodes the key into.
+func lookupYAMLField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if strings.Contains(opts, "inline") && f.Type.Kind() == reflect.Struct {
+			if ef, ok := lookupYAMLField(f.Type, key); ok {
+				return ef, true
+			}
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if name == key {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
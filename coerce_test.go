@@ -0,0 +1,166 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithStringCoercion(t *testing.T) {
+	type limits struct {
+		MaxSize ByteSize `json:"max_size"`
+	}
+	type base struct {
+		Idle time.Duration `json:"idle"`
+	}
+	type coerceConfig struct {
+		base
+		Timeout  time.Duration            `json:"timeout"`
+		Retry    *time.Duration           `json:"retry"`
+		Limits   limits                   `json:"limits"`
+		Backoffs []time.Duration          `json:"backoffs"`
+		PerHost  map[string]time.Duration `json:"per_host"`
+		Name     string                   `json:"name"`
+		Plain    time.Duration
+	}
+
+	write := func(t *testing.T, data string) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(data), 0o600))
+	}
+
+	t.Run("Positive", func(t *testing.T) {
+		write(t, `{
+			"idle": "1m",
+			"timeout": "30s",
+			"retry": "2s",
+			"limits": {"max_size": "10MB"},
+			"backoffs": ["1s", 2000000000],
+			"per_host": {"a": "5s"},
+			"name": "30s",
+			"plain": "1h"
+		}`)
+
+		cfg := &coerceConfig{}
+		require.NoError(t, New(cfg, WithStringCoercion[coerceConfig]()))
+
+		retry := 2 * time.Second
+		assert.Equal(t, &coerceConfig{
+			base:     base{Idle: time.Minute},
+			Timeout:  30 * time.Second,
+			Retry:    &retry,
+			Limits:   limits{MaxSize: 10 * MB},
+			Backoffs: []time.Duration{time.Second, 2 * time.Second},
+			PerHost:  map[string]time.Duration{"a": 5 * time.Second},
+			Name:     "30s",
+			Plain:    time.Hour,
+		}, cfg)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		write(t, `{"timeout": "30s"}`)
+		assert.Error(t, New(&coerceConfig{}))
+	})
+
+	t.Run("InvalidDuration", func(t *testing.T) {
+		write(t, `{"limits": {"max_size": "1MB"}, "backoffs": ["1s", "soon"]}`)
+		err := New(&coerceConfig{}, WithStringCoercion[coerceConfig]())
+		assert.ErrorContains(t, err, "backoffs[1]")
+	})
+
+	t.Run("InvalidSize", func(t *testing.T) {
+		write(t, `{"limits": {"max_size": "huge"}}`)
+		err := New(&coerceConfig{}, WithStringCoercion[coerceConfig]())
+		assert.ErrorContains(t, err, "limits.max_size")
+	})
+}
+
+func TestWithStringCoercionTime(t *testing.T) {
+	type event struct {
+		At time.Time `json:"at" yaml:"at" config:"at"`
+	}
+	type timeConfig struct {
+		Start  time.Time   `json:"start" yaml:"start" toml:"start" config:"start"`
+		Date   time.Time   `json:"date" yaml:"date" toml:"date" config:"date"`
+		Local  time.Time   `json:"local" yaml:"local" toml:"local" config:"local"`
+		Events []event     `json:"events" yaml:"events" toml:"-" config:"-"`
+		Until  *time.Time  `json:"until" yaml:"until" toml:"-" config:"-"`
+		Plain  []time.Time `json:"plain" yaml:"plain" toml:"-" config:"-"`
+	}
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("", 2*60*60))
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	local := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	load := func(t *testing.T, name, data string, opts ...Option[timeConfig]) (*timeConfig, error) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, path.Join(dir, name))
+		require.NoError(t, os.WriteFile(path.Join(dir, name), []byte(data), 0o600))
+		cfg := &timeConfig{}
+		return cfg, New(cfg, opts...)
+	}
+
+	for name, data := range map[string]string{
+		"config.json": `{"start": "2024-05-01T10:00:00+02:00", "date": "2024-05-01", "local": "2024-05-01T10:30:00",
+			"events": [{"at": "2024-05-01"}], "until": "2024-05-01", "plain": ["2024-05-01 00:00:00"]}`,
+		"config.yaml": "start: \"2024-05-01T10:00:00+02:00\"\ndate: \"2024-05-01\"\nlocal: \"2024-05-01 10:30:00\"\n" +
+			"events:\n  - at: \"2024-05-01\"\nuntil: \"2024-05-01\"\nplain: [\"2024-05-01\"]\n",
+		"config.toml": "start = 2024-05-01T10:00:00+02:00\ndate = 2024-05-01\nlocal = 2024-05-01T10:30:00\n",
+		"config.ini":  "start = 2024-05-01T10:00:00+02:00\ndate = 2024-05-01\nlocal = \"2024-05-01 10:30:00\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := load(t, name, data, WithStringCoercion[timeConfig]())
+			require.NoError(t, err)
+			assert.True(t, start.Equal(cfg.Start), cfg.Start)
+			assert.True(t, date.Equal(cfg.Date), cfg.Date)
+			if name == "config.toml" {
+				// TOML reads local datetimes in the local time zone
+				assert.Equal(t, local.Format(time.DateTime), cfg.Local.Format(time.DateTime))
+				return
+			}
+			assert.True(t, local.Equal(cfg.Local), cfg.Local)
+			if path.Ext(name) == ".ini" {
+				return
+			}
+			if assert.Len(t, cfg.Events, 1) {
+				assert.True(t, date.Equal(cfg.Events[0].At))
+			}
+			if assert.NotNil(t, cfg.Until) {
+				assert.True(t, date.Equal(*cfg.Until))
+			}
+			if assert.Len(t, cfg.Plain, 1) {
+				assert.True(t, date.Equal(cfg.Plain[0]))
+			}
+		})
+	}
+
+	for _, ext := range []string{"json", "yaml", "toml", "ini"} {
+		t.Run("Marshal "+ext, func(t *testing.T) {
+			until := date
+			data, err := Marshal(&timeConfig{Start: start, Date: date, Local: local, Until: &until}, ext)
+			require.NoError(t, err)
+
+			cfg, err := load(t, "config."+ext, string(data), WithStringCoercion[timeConfig]())
+			require.NoError(t, err)
+			assert.True(t, start.Equal(cfg.Start), cfg.Start)
+			assert.True(t, date.Equal(cfg.Date), cfg.Date)
+			assert.True(t, local.Equal(cfg.Local), cfg.Local)
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, err := load(t, "config.json", `{"date": "2024-05-01"}`)
+		assert.Error(t, err)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := load(t, "config.yaml", "events:\n  - at: \"tomorrow\"\n", WithStringCoercion[timeConfig]())
+		assert.ErrorContains(t, err, "events[0].at")
+	})
+}
@@ -0,0 +1,58 @@
+package confix
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathNotConfined is returned when a configuration path escapes the directory set with WithConfineTo.
+var ErrPathNotConfined = errors.New("config path outside of confined directory")
+
+// checkConfined returns an error wrapping ErrPathNotConfined for the first of paths that doesn't lie
+// inside the confineTo directory once both are cleaned and their symlinks are resolved.
+// It does nothing when confineTo is not set.
+func (c *config[T]) checkConfined(paths ...string) error {
+	if c.confineTo == "" {
+		return nil
+	}
+
+	base, err := resolvePath(c.confineTo)
+	if err != nil {
+		return fmt.Errorf("error while resolving %s: %w", c.confineTo, err)
+	}
+	for _, p := range paths {
+		resolved, err := resolvePath(p)
+		if err != nil {
+			return fmt.Errorf("error while resolving %s: %w", p, err)
+		}
+		rel, err := filepath.Rel(base, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %s", ErrPathNotConfined, p)
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute form of p with symlinks resolved. Only the longest existing
+// prefix of p is resolved, so that files which are yet to be created can be checked as well.
+func resolvePath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) || filepath.Dir(dir) == dir {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
@@ -0,0 +1,91 @@
+package confix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithConfineTo(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "app")
+	outside := filepath.Join(root, "outside")
+	require.NoError(t, os.MkdirAll(filepath.Join(base, "conf"), 0o700))
+	require.NoError(t, os.MkdirAll(outside, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "config.json"), []byte(`{"a": "outside"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+
+	t.Run("positive", func(t *testing.T) {
+		fpath := filepath.Join(base, "conf", "..", "config.json")
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := &testConfig{A: "a"}
+		require.NoError(t, New(cfg, WithConfineTo[testConfig](base)))
+		assert.FileExists(t, fpath)
+	})
+	t.Run("negative: traversal", func(t *testing.T) {
+		fpath := filepath.Join(base, "..", "created.json")
+		t.Setenv(FilePathEnvName, fpath)
+
+		err := New(new(testConfig), WithConfineTo[testConfig](base))
+		assert.ErrorIs(t, err, ErrPathNotConfined)
+		assert.NoFileExists(t, fpath)
+	})
+	t.Run("negative: symlink", func(t *testing.T) {
+		if err := os.Symlink(outside, filepath.Join(base, "link")); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+		t.Setenv(FilePathEnvName, filepath.Join(base, "link", "config.json"))
+
+		cfg := new(testConfig)
+		assert.ErrorIs(t, New(cfg, WithConfineTo[testConfig](base)), ErrPathNotConfined)
+		assert.Empty(t, cfg.A)
+
+		t.Setenv(FilePathEnvName, filepath.Join(base, "link", "new.json"))
+		assert.ErrorIs(t, New(cfg, WithConfineTo[testConfig](base)), ErrPathNotConfined)
+		assert.NoFileExists(t, filepath.Join(outside, "new.json"))
+	})
+	t.Run("negative: directory", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, outside)
+
+		assert.ErrorIs(t, New(new(testConfig), WithConfineTo[testConfig](base)), ErrPathNotConfined)
+	})
+	t.Run("negative: overlay", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, filepath.Join(base, "config.json"))
+
+		cfg := new(testConfig)
+		err := New(cfg, WithConfineTo[testConfig](base), WithOverlay[testConfig](filepath.Join(outside, "config.json")))
+		assert.ErrorIs(t, err, ErrPathNotConfined)
+		assert.NotEqual(t, "outside", cfg.A)
+	})
+	t.Run("negative: glob", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, filepath.Join(base, "config.json"))
+
+		cfg := new(testConfig)
+		err := New(cfg, WithConfineTo[testConfig](base), WithGlob[testConfig](filepath.Join(base, "..", "outside", "*.json")))
+		assert.ErrorIs(t, err, ErrPathNotConfined)
+		assert.NotEqual(t, "outside", cfg.A)
+	})
+	t.Run("negative: write", func(t *testing.T) {
+		c := &config[testConfig]{cfg: &testConfig{A: "written"}}
+		c.confineTo = base
+		fpath := filepath.Join(outside, "written.json")
+		assert.ErrorIs(t, c.writeToFile(fpath), ErrPathNotConfined)
+		assert.NoFileExists(t, fpath)
+	})
+	t.Run("negative: backup", func(t *testing.T) {
+		fpath := filepath.Join(base, "backup.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "before"}`), 0o600))
+		c := &config[testConfig]{cfg: &testConfig{A: "written"}}
+		c.confineTo = base
+		c.backupSuffix = string(filepath.Separator) + filepath.Join("..", "..", "outside", "backup.json")
+		assert.ErrorIs(t, c.writeToFile(fpath), ErrPathNotConfined)
+		assert.NoFileExists(t, filepath.Join(outside, "backup.json"))
+	})
+}
@@ -1,67 +1,322 @@
-// Package confix provides a configuration management system that supports JSON, TOML, and YAML formats.
+// Package confix provides a configuration management system that supports JSON, TOML, YAML, and INI formats.
 // It allows reading configuration from files and environment variables, with support for validation
 // and synchronization across multiple configuration files.
 package confix
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"crypto/cipher"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
-	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
-
-	"github.com/BurntSushi/toml"
-	"gopkg.in/yaml.v3"
+	"time"
 )
 
 // encoder interface defines the contract for encoding configuration data to different formats.
-// Implementations include JSON, TOML, and YAML encoders.
+// Implementations include JSON, TOML, YAML, and INI encoders and adapters of registered formats.
 type encoder interface {
 	Encode(interface{}) error
 }
 
-var currentDir, _ = os.Executable()
+// executableDir returns the directory containing the running executable
+// or an empty string when it can't be determined.
+func executableDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Dir(exe)
+}
+
+// defaultWriteConcurrency is the number of files written at once when WithWriteConcurrency is not set.
+const defaultWriteConcurrency = 4
+
+// stdinPath is the path in FilePathEnvName standing for the standard input.
+const stdinPath = "-"
+
+// defaultConfigBaseName is the base name of configuration files used when BaseNameEnvName is not set.
+const defaultConfigBaseName = "config"
 
 const (
-	jsonConfigFileName = "config.json"
-	tomlConfigFileName = "config.toml"
-	yamlConfigFileName = "config.yaml"
-	ymlConfigFileName  = "config.yml"
+	jsonExt = ".json"
+	tomlExt = ".toml"
+	yamlExt = ".yaml"
+	ymlExt  = ".yml"
+	iniExt  = ".ini"
+)
+
+// skippedSourcesError holds the errors of sources skipped because of WithContinueOnError.
+// The sources that decoded successfully are still applied when it is returned.
+type skippedSourcesError []error
+
+// Error returns the messages of the errors, one per line.
+func (e skippedSourcesError) Error() string {
+	return errors.Join(e...).Error()
+}
+
+// Unwrap returns the errors of the skipped sources.
+func (e skippedSourcesError) Unwrap() []error {
+	return e
+}
+
+// MissingFilesError is returned when files that must exist, e.g. overlays with WithRequireOverlays, are missing.
+type MissingFilesError struct {
+	// Paths lists the missing files in the order they were given
+	Paths []string
+}
+
+// Error returns the message listing the missing files.
+func (e *MissingFilesError) Error() string {
+	return "missing config files: " + strings.Join(e.Paths, ", ")
+}
+
+var (
+	// ErrUnsupportedExtension is returned when no decoder or encoder matches the file extension.
+	ErrUnsupportedExtension = errors.New("unsupported file extension")
+	// ErrNoConfigFound is returned when a configuration file is required but none was found.
+	ErrNoConfigFound = errors.New("no config file found")
+	// ErrEmptyConfigFile is returned for an empty configuration file that was given explicitly
+	// when empty files are errors.
+	ErrEmptyConfigFile = errors.New("config file is empty")
 )
 
 var (
 	// DirEnvName is the environment variable name for specifying the configuration directory path
+	// or a list of them separated by os.PathListSeparator
 	DirEnvName = "CONFIG_DIR_PATH"
 	// FilePathEnvName is the environment variable name for specifying the configuration file path
+	// or a list of them separated by os.PathListSeparator; "-" stands for the standard input
 	FilePathEnvName = "CONFIG_FILE_PATH"
+	// BaseNameEnvName is the environment variable name for specifying the base name of configuration files
+	BaseNameEnvName = "CONFIG_BASE_NAME"
+	// DefaultFileMode is the permission of configuration files written by confix when the target doesn't exist yet.
+	// Existing files keep their permissions.
+	DefaultFileMode os.FileMode = 0o600
 )
 
 // config represents a configuration instance with type parameter T.
 type config[T any] struct {
+	settings
 	// paths contains the list of configuration file paths to be processed
 	paths []string
+	// candidates contains every path considered when paths were resolved, from the lowest to the highest priority
+	candidates []string
+	// layerPaths makes load merge every file in paths, as for a list of files in FilePathEnvName
+	layerPaths bool
+	// explicitPaths reports that paths were set by FilePathEnvName, an option or the standard input
+	// rather than discovered
+	explicitPaths bool
 	// cfg holds the pointer to the actual configuration structure
 	cfg *T
+	// mu guards cfg against concurrent reloads
+	mu sync.RWMutex
+	// result records the outcome of loading configuration files
+	result Result
+	// resultMu guards result against concurrent writes
+	resultMu sync.Mutex
+	// skipped collects the errors of sources skipped by load with continueOnError
+	skipped []error
+	// sources contains the paths of configuration files that load decoded or found empty,
+	// the files written back when syncing
+	sources []string
+	// sniffed holds the extensions of the formats detected from the contents of files
+	// with unrecognized extensions by their paths, so that the files are written in the same format
+	sniffed map[string]string
+	// secretRefs holds the secret references resolved by WithSecretResolver by the paths of their fields,
+	// which are written instead of the secrets
+	secretRefs map[string]secretRef
+	// sealed holds the ciphertexts of the decrypted fields tagged with the encrypted modifier by their names,
+	// which writes keep while the fields hold the same values
+	sealed map[string]string
+	// after holds the options applied after loading, sorted by phase, which reloads apply again
+	after []afterOption[T]
+}
+
+// envSnapshot holds the environment variables selecting configuration files.
+type envSnapshot struct {
+	filePath string
+	dir      string
+	baseName string
+}
+
+// snapshotEnv reads the environment variables selecting configuration files.
+func snapshotEnv() envSnapshot {
+	return envSnapshot{
+		filePath: os.Getenv(FilePathEnvName),
+		dir:      os.Getenv(DirEnvName),
+		baseName: os.Getenv(BaseNameEnvName),
+	}
+}
+
+// firstEnv returns the value of the first of the environment variables names that is set and not empty.
+func firstEnv(names []string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// newSettings returns the settings of a new configuration instance before options are applied.
+func newSettings() settings {
+	return settings{
+		env:     snapshotEnv(),
+		baseDir: executableDir(),
+	}
+}
+
+// settings holds the behavior of a configuration instance set by options.
+type settings struct {
+	// baseDir is searched for configuration files when no files or directories are set,
+	// the directory of the running executable by default
+	baseDir string
+	// env holds the environment variables selecting configuration files, captured at construction
+	env envSnapshot
+	// configFile lists configuration files like FilePathEnvName and takes precedence over the environment
+	configFile string
+	// configDir lists configuration directories like DirEnvName and takes precedence over the environment
+	configDir string
+	// logger receives diagnostic messages, the standard logger is used when nil
+	logger Logger
+	// requireFile makes initialization fail when no configuration file is found
+	requireFile bool
+	// formatPriority lists file extensions from the lowest to the highest priority
+	formatPriority []string
+	// mergeAll makes load decode every found file instead of only the highest-priority one
+	mergeAll bool
+	// overlays lists files deep-merged on top of the loaded configuration in order
+	overlays []string
+	// requireOverlays makes load fail when an overlay file doesn't exist
+	requireOverlays bool
+	// globs lists patterns of drop-in files deep-merged after the configuration files
+	globs []string
+	// remoteSources are decoded into the configuration before local files
+	remoteSources []remoteSource
+	// skipUnchanged makes writes leave files with identical contents untouched
+	skipUnchanged bool
+	// backupSuffix enables copying an existing file to its path with the suffix before overwriting
+	backupSuffix string
+	// dryRun makes writes record planned writes instead of touching files
+	dryRun bool
+	// encoderOptions customizes the formatting of written files
+	encoderOptions EncoderOptions
+	// keyPolicy derives the keys of fields without a config tag from their names in every format
+	keyPolicy func(fieldName string) string
+	// writeHeader holds the lines of the header stamped on written files, headers are off when nil
+	writeHeader []string
+	// jsonMeta makes the write header be stored under the _meta key of JSON files
+	jsonMeta bool
+	// errorOnEmpty makes empty files given explicitly fail loading instead of being skipped
+	errorOnEmpty bool
+	// noCreate keeps a missing file set by FilePathEnvName from being created
+	noCreate bool
+	// debounce is the window in which file events are coalesced into a single reload by Watch,
+	// DefaultDebounce is used when zero
+	debounce time.Duration
+	// tempDir holds temp files of writes, the directory of the target is used when empty
+	tempDir string
+	// durable makes writes sync the temp file before the rename and the directory after it
+	durable bool
+	// fileMode is the permission of created configuration files, DefaultFileMode is used when zero
+	fileMode os.FileMode
+	// confineTo is the directory every resolved configuration file must lie in
+	confineTo string
+	// xdgAppName enables searching the XDG config directory of the application
+	xdgAppName string
+	// aead decrypts encrypted files and fields when loading and encrypts them when writing
+	aead cipher.AEAD
+	// strict makes decoding fail on keys that don't match any field
+	strict bool
+	// coerce makes strings given for time.Duration and ByteSize fields in JSON files
+	// and for time.Time fields in JSON, YAML and INI files be parsed
+	coerce bool
+	// jsonComments makes comments and trailing commas in JSON files be ignored
+	jsonComments bool
+	// syncPrimaryOnly makes syncing write only the primary configuration file
+	syncPrimaryOnly bool
+	// writeConcurrency limits the number of files written at once, defaultWriteConcurrency is used when zero
+	writeConcurrency int
+	// yamlRoundTrip makes writes update existing YAML files keeping their comments and anchors
+	yamlRoundTrip bool
+	// continueOnError makes load skip sources that fail instead of stopping at the first one
+	continueOnError bool
+	// stdinFormat is the format of configuration read from the standard input
+	stdinFormat string
+	// subtree is the dotted key path of the part of configuration files decoded into the configuration
+	subtree string
+	// ignoreUnsupported makes overlays with unsupported extensions be skipped instead of failing load
+	ignoreUnsupported bool
+	// sniffFormat makes files with unrecognized extensions be decoded in the format detected from their contents
+	sniffFormat bool
+}
+
+// Result describes which configuration files were processed during initialization.
+type Result struct {
+	// LoadedPaths contains the paths of files that were successfully decoded, in load order
+	LoadedPaths []string
+	// SkippedEmpty contains the paths of files that were skipped because they were empty
+	SkippedEmpty []string
+	// SkippedMissing contains the paths of files that were skipped because they did not exist
+	SkippedMissing []string
+	// PlannedWrites contains the writes that were skipped because of WithDryRun
+	PlannedWrites []PlannedWrite
+	// Created reports that a file set by FilePathEnvName didn't exist and was created
+	// with the initial configuration, e.g. on the first run
+	Created bool
+	// CreatedPaths contains the paths of the created files
+	CreatedPaths []string
+}
+
+// PlannedWrite describes a write of a configuration file that WithDryRun prevented.
+type PlannedWrite struct {
+	// Path is the path of the file that would have been written
+	Path string
+	// Data is the encoded configuration that would have been written
+	Data []byte
 }
 
 // SetConfigDir sets the directory path for configuration files through environment variable.
 // The path will be used to look for configuration files with supported extensions.
+// Several directories can be given separated by os.PathListSeparator, e.g. "/etc/app:/usr/local/etc/app";
+// files in later directories have higher priority.
+// WithConfigDir sets the directory for a single instance without touching the environment.
 func SetConfigDir(dir string) error {
 	return os.Setenv(DirEnvName, dir)
 }
 
 // SetConfigPath sets the specific configuration file path through environment variable.
 // This path will be used instead of searching for configuration files in directories.
+// Several files can be given separated by os.PathListSeparator; they are deep-merged in order.
+// WithConfigFile sets the file for a single instance without touching the environment.
 func SetConfigPath(path string) error {
 	return os.Setenv(FilePathEnvName, path)
 }
 
+// SetConfigBaseName sets the base name of configuration files through environment variable.
+// The name replaces the default "config" when looking for files, e.g. "settings" yields settings.json.
+func SetConfigBaseName(name string) error {
+	return os.Setenv(BaseNameEnvName, name)
+}
+
 // New initializes and parsing config.
 func New[T any](cfg *T, afterInit ...Option[T]) error {
-	_, err := newConfig[T](cfg, afterInit...)
+	return NewContext[T](context.Background(), cfg, afterInit...)
+}
+
+// NewContext initializes and parses config the same way as New.
+// Reading stops with the error of ctx once it is cancelled or its deadline is exceeded.
+func NewContext[T any](ctx context.Context, cfg *T, opts ...Option[T]) error {
+	_, err := newConfig[T](ctx, cfg, opts...)
 	if err != nil {
 		return err
 	}
@@ -69,150 +324,840 @@ func New[T any](cfg *T, afterInit ...Option[T]) error {
 	return nil
 }
 
-// newConfig initializes a new configuration instance with the provided configuration structure
-// and applies any optional functions after initialization.
-func newConfig[T any](cfg *T, afterFunc ...Option[T]) (*config[T], error) {
+// NewWithResult initializes and parses config the same way as New and reports
+// which configuration files were loaded or skipped and, with WithDryRun, which writes were planned.
+func NewWithResult[T any](cfg *T, opts ...Option[T]) (Result, error) {
+	c, err := newConfig[T](context.Background(), cfg, opts...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return c.result, nil
+}
+
+// NewFromReader initializes config from a single stream instead of files.
+// The decoder is chosen by format, one of "json", "yaml", "yml", "toml" or "ini".
+// The stream takes the place of the configuration files: remote sources are decoded before it,
+// drop-in files and overlays are merged on top of it, and with WithDecryption encrypted streams
+// and fields are decrypted.
+func NewFromReader[T any](cfg *T, r io.Reader, format string, opts ...Option[T]) error {
 	c := &config[T]{
-		cfg:   cfg,
-		paths: []string{},
+		settings: newSettings(),
+		cfg:      cfg,
+		paths:    []string{},
+	}
+
+	return c.initialize(opts, func() error {
+		c.remoteSources = append(slices.Clip(c.remoteSources), c.readerSource(r, "."+strings.TrimPrefix(format, ".")))
+		return c.load(context.Background())
+	})
+}
+
+// readerSource returns a source decoding the stream r in the format of the extension ext,
+// decrypting it first when it is encrypted as a whole.
+func (c *config[T]) readerSource(r io.Reader, ext string) remoteSource {
+	return func(_ context.Context, v any, opts decodeOptions) error {
+		if c.aead != nil {
+			var err error
+			if r, err = decryptFile(c.aead, r); err != nil {
+				return fmt.Errorf("error while reading stream: %w", err)
+			}
+		}
+		opts.source = "reader"
+		return decode(r, ext, v, opts)
+	}
+}
+
+// Dump writes cfg to w encoded in format, one of "json", "yaml", "yml", "toml", "ini"
+// or the extension of a registered format, e.g. to show the effective configuration.
+// Unknown formats return ErrUnsupportedExtension.
+func Dump[T any](cfg *T, format string, w io.Writer) error {
+	ext := "." + strings.TrimPrefix(format, ".")
+	e, err := getEncoderForFile(ext, w, EncoderOptions{})
+	if err != nil {
+		return err
 	}
+	return encodeWithCodecs(e, ext, cfg, EncoderOptions{})
+}
 
-	err := c.getConfigPaths()
+// Marshal returns cfg encoded in the format of the extension ext, e.g. ".yaml" or "yaml",
+// the same way as configuration files are written, e.g. to send it over the wire.
+// Unknown extensions return ErrUnsupportedExtension.
+func Marshal[T any](cfg *T, ext string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	e, err := getEncoderForFile(normalizeExt(ext), buf, EncoderOptions{})
 	if err != nil {
 		return nil, err
 	}
+	if err = encodeWithCodecs(e, normalizeExt(ext), cfg, EncoderOptions{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	err = c.load()
+// Unmarshal decodes data in the format of the extension ext, e.g. ".yaml" or "yaml", into cfg,
+// e.g. configuration read from a message queue or a database column. Unknown extensions return
+// ErrUnsupportedExtension. Options configuring decoding, such as WithStrictDecoding and
+// WithStringCoercion, are honored; no default values are set and options applied after loading,
+// such as validation and writing options, are ignored. Use NewFromReader to apply them.
+func Unmarshal[T any](data []byte, ext string, cfg *T, opts ...Option[T]) error {
+	c := &config[T]{
+		settings: newSettings(),
+		cfg:      cfg,
+		paths:    []string{},
+	}
+	for _, f := range opts {
+		if _, ok := f.(afterOption[T]); ok {
+			continue
+		}
+		if err := f.apply(c); err != nil {
+			return err
+		}
+	}
+
+	return decode(bytes.NewReader(data), normalizeExt(ext), c.cfg, c.decodeOptions(""))
+}
+
+// Validate applies the validation functions to cfg and returns their errors joined, the same way
+// as WithValidations does after loading. No files or environment variables are read,
+// so validation rules can be tested on configurations built in code.
+func Validate[T any](cfg *T, validators ...func(cfg *T) error) error {
+	var errs []error
+	for _, f := range validators {
+		if err := f(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Defaults returns a configuration holding the values of its default tags, e.g. to print
+// the default configuration or to generate an example file. No files are read; options are
+// applied the same way as in New, so WithEnvOverrides adds environment variables on top of
+// the defaults. Unlike New, Defaults doesn't fail on required fields left unset.
+func Defaults[T any](opts ...Option[T]) (*T, error) {
+	c := &config[T]{
+		settings: newSettings(),
+		cfg:      new(T),
+		paths:    []string{},
+	}
+
+	err := c.run(opts, func() error { return nil })
 	if err != nil {
 		return nil, err
 	}
 
-	for _, f := range afterFunc {
-		if err = f.apply(c); err != nil {
-			return nil, err
+	return c.cfg, nil
+}
+
+// LoadInto decodes the configuration files resolved the same way as in New into cfg, e.g. to report
+// the configuration currently on disk from a health check. Unlike New it has no side effects:
+// missing files are not created, and no default values, options or required checks are applied.
+func LoadInto[T any](cfg *T) error {
+	c := &config[T]{
+		settings: newSettings(),
+		cfg:      cfg,
+		paths:    []string{},
+	}
+	c.noCreate = true
+
+	if err := c.getConfigPaths(); err != nil {
+		return err
+	}
+	return c.load(context.Background())
+}
+
+// newConfig initializes a new configuration instance with the provided configuration structure
+// and applies any optional functions after initialization.
+func newConfig[T any](ctx context.Context, cfg *T, afterFunc ...Option[T]) (*config[T], error) {
+	c := &config[T]{
+		settings: newSettings(),
+		cfg:      cfg,
+		paths:    []string{},
+	}
+
+	err := c.initialize(afterFunc, func() error {
+		if err := c.getConfigPaths(); err != nil {
+			return err
 		}
+		if c.requireFile && len(getExistingPaths(c.paths...)) == 0 && !slices.Contains(c.paths, stdinPath) {
+			return ErrNoConfigFound
+		}
+		return c.load(ctx)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return c, nil
 }
 
-// encodeToFile encodes the configuration data to the specified file using the appropriate encoder
-// based on the file extension.
-func (c *config[T]) encodeToFile(f *os.File) error {
-	e, err := getEncoderForFile(path.Ext(f.Name()), f)
+// initialize applies the options configuring the instance and the default values, runs load
+// and then applies the remaining options phase by phase: transform, validate and persist.
+// Required fields are checked first in the validate phase.
+func (c *config[T]) initialize(opts []Option[T], load func() error) error {
+	return c.run(opts, load, afterOption[T]{
+		phase: phaseValidate,
+		f: func(c *config[T]) error {
+			return checkRequired(reflect.ValueOf(c.cfg).Elem(), "")
+		},
+	})
+}
+
+// run applies the options configuring the instance and the default values, runs load
+// and then applies after together with the remaining options phase by phase.
+func (c *config[T]) run(opts []Option[T], load func() error, after ...afterOption[T]) error {
+	for _, f := range opts {
+		if o, ok := f.(afterOption[T]); ok {
+			after = append(after, o)
+			continue
+		}
+		if err := f.apply(c); err != nil {
+			return err
+		}
+	}
+
+	err := applyDefaults(reflect.ValueOf(c.cfg).Elem())
 	if err != nil {
 		return err
 	}
 
-	if err = e.Encode(c.cfg); err != nil {
+	// with WithContinueOnError skipped sources are reported after the remaining options run
+	var skipped skippedSourcesError
+	err = load()
+	if err != nil && !errors.As(err, &skipped) {
 		return err
 	}
+
+	sort.SliceStable(after, func(i, j int) bool {
+		return after[i].phase < after[j].phase
+	})
+	c.after = after
+	for _, o := range after {
+		if err := o.apply(c); err != nil {
+			if skipped != nil {
+				return errors.Join(skipped, err)
+			}
+			return err
+		}
+	}
+
+	if skipped != nil {
+		return skipped
+	}
 	return nil
 }
 
-// getConfigPaths determines the configuration file paths based on environment variables
-// and default locations.
+// writtenValue returns the configuration as written to files: with the references of resolved secrets
+// instead of the secrets and with encrypted fields encrypted. The configuration itself is left untouched.
+func (c *config[T]) writtenValue() (*T, error) {
+	v := c.cfg
+	if len(c.secretRefs) > 0 {
+		v = withSecretRefs(v, c.secretRefs)
+	}
+	if c.aead != nil {
+		return encryptedCopy(c.aead, v, c.sealed)
+	}
+	return v, nil
+}
+
+// encode encodes the configuration data to w using the appropriate encoder
+// based on the file extension ext.
+func (c *config[T]) encode(w io.Writer, ext string) error {
+	e, err := getEncoderForFile(ext, w, c.encoderOptions)
+	if err != nil {
+		return err
+	}
+
+	v, err := c.writtenValue()
+	if err != nil {
+		return err
+	}
+
+	if c.keyPolicy != nil {
+		if f, _ := lookupFormat(ext); f.name == "ini" {
+			e = &iniEncoder{w: w, keyPolicy: c.keyPolicy}
+		}
+		return encodeWithKeyPolicy(e, ext, v, c.encoderOptions, c.keyPolicy)
+	}
+	if err = encodeWithCodecs(e, ext, v, c.encoderOptions); err != nil {
+		return err
+	}
+	return nil
+}
+
+// fileExt returns the extension selecting the format of the file at p: the extension of the format
+// detected from its contents when it was loaded with sniffFormat, the extension of p otherwise.
+func (c *config[T]) fileExt(p string) string {
+	if ext, ok := c.sniffed[p]; ok {
+		return ext
+	}
+	return filepath.Ext(p)
+}
+
+// encodeFile encodes the configuration data to w for the file at fPath. With yamlRoundTrip
+// an existing YAML file is updated so that its comments, anchors and merge keys are kept,
+// with subtree only the subtree of an existing file is replaced.
+func (c *config[T]) encodeFile(w io.Writer, fPath string) error {
+	if c.subtree != "" {
+		return c.encodeSubtree(w, fPath)
+	}
+	ext := c.fileExt(fPath)
+	if f, ok := lookupFormat(ext); ok && f.name == "yaml" && c.yamlRoundTrip {
+		if current, readErr := os.ReadFile(fPath); readErr == nil && !isEncryptedFile(current) {
+			if c.writeHeader != nil {
+				current = c.stripWriteHeader(current, ext)
+			}
+			buf := &bytes.Buffer{}
+			done, err := c.encodeYAMLRoundTrip(buf, current)
+			if err != nil {
+				return err
+			}
+			if done {
+				_, err = w.Write(buf.Bytes())
+				return err
+			}
+		}
+	}
+	return c.encode(w, ext)
+}
+
+// getConfigPaths determines the configuration file paths based on the files and directories
+// set by options, the environment variables captured at construction and default locations.
 func (c *config[T]) getConfigPaths() error {
-	switch configPath, configDir := os.Getenv(FilePathEnvName), os.Getenv(DirEnvName); {
+	base := c.env.baseName
+	if base == "" {
+		base = defaultConfigBaseName
+	}
+
+	configPath, configDir := c.env.filePath, c.env.dir
+	if c.configFile != "" || c.configDir != "" {
+		configPath, configDir = c.configFile, c.configDir
+	}
+
+	switch {
 
 	case configPath != "":
-		return c.setConfigPathForOneFile(configPath)
+		c.paths = nil
+		for _, p := range filepath.SplitList(configPath) {
+			if p == "" {
+				continue
+			}
+			if p == stdinPath {
+				c.paths = append(c.paths, p)
+				continue
+			}
+			if err := c.setConfigPathForOneFile(p); err != nil {
+				return err
+			}
+		}
+		c.candidates = c.paths
+		c.layerPaths = len(c.paths) > 1
+		c.explicitPaths = true
+		return nil
 
-	case configDir != "":
-		c.paths = getExistingPaths(
-			path.Join(configDir, jsonConfigFileName),
-			path.Join(configDir, tomlConfigFileName),
-			path.Join(configDir, ymlConfigFileName),
-			path.Join(configDir, yamlConfigFileName),
-		)
+	case c.stdinFormat != "":
+		c.paths = []string{stdinPath}
+		c.candidates = c.paths
+		c.explicitPaths = true
 		return nil
+
+	case configDir != "":
+		c.candidates = nil
+		for _, dir := range filepath.SplitList(configDir) {
+			if dir == "" {
+				continue
+			}
+			c.candidates = append(c.candidates, c.sortByFormatPriority([]string{
+				filepath.Join(dir, base+jsonExt),
+				filepath.Join(dir, base+tomlExt),
+				filepath.Join(dir, base+ymlExt),
+				filepath.Join(dir, base+yamlExt),
+				filepath.Join(dir, base+iniExt),
+			})...)
+		}
+		c.paths = getExistingPaths(c.candidates...)
+		return c.checkConfined(c.paths...)
+	default:
+		var candidates []string
+		if c.xdgAppName != "" {
+			dir, err := xdgConfigDir()
+			if err != nil {
+				return err
+			}
+			dir = filepath.Join(dir, c.xdgAppName)
+			candidates = append(candidates,
+				filepath.Join(dir, base+tomlExt),
+				filepath.Join(dir, base+jsonExt),
+				filepath.Join(dir, base+ymlExt),
+				filepath.Join(dir, base+yamlExt),
+				filepath.Join(dir, base+iniExt),
+			)
+		}
+		if c.baseDir != "" {
+			candidates = append(candidates,
+				filepath.Join(c.baseDir, base+tomlExt),
+				filepath.Join(c.baseDir, base+jsonExt),
+				filepath.Join(c.baseDir, base+ymlExt),
+				filepath.Join(c.baseDir, base+yamlExt),
+				filepath.Join(c.baseDir, base+iniExt),
+			)
+		}
+		c.candidates = c.sortByFormatPriority(append(candidates,
+			base+tomlExt,
+			base+jsonExt,
+			base+ymlExt,
+			base+yamlExt,
+			base+iniExt,
+		))
+		c.paths = getExistingPaths(c.candidates...)
+		return c.checkConfined(c.paths...)
+	}
+}
+
+// primaryPath returns the highest-priority existing configuration file or, when none exists,
+// the highest-priority candidate. It returns an empty string when there are no candidates.
+func (c *config[T]) primaryPath() string {
+	switch {
+	case len(c.paths) > 0:
+		return c.paths[len(c.paths)-1]
+	case len(c.candidates) > 0:
+		return c.candidates[len(c.candidates)-1]
 	default:
-		c.paths = getExistingPaths(
-			path.Join(currentDir, tomlConfigFileName),
-			path.Join(currentDir, jsonConfigFileName),
-			path.Join(currentDir, ymlConfigFileName),
-			path.Join(currentDir, yamlConfigFileName),
-			tomlConfigFileName,
-			jsonConfigFileName,
-			ymlConfigFileName,
-			yamlConfigFileName,
-		)
+		return ""
+	}
+}
+
+// xdgConfigDir returns the user configuration directory according to the XDG base directory
+// specification: XDG_CONFIG_HOME when set, ~/.config otherwise.
+func xdgConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error while resolving XDG config directory: %w", err)
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+// sortByFormatPriority orders paths by the position of their extension in the format priority
+// so that files with higher priority are loaded later and override the others.
+// Paths with extensions missing from the priority list come first; the order is kept
+// for paths with equal priority. Without a format priority paths are returned as is.
+func (c *config[T]) sortByFormatPriority(paths []string) []string {
+	if len(c.formatPriority) == 0 {
+		return paths
+	}
+
+	rank := make(map[string]int, len(c.formatPriority))
+	for i, ext := range c.formatPriority {
+		rank[normalizeExt(ext)] = i + 1
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return rank[normalizeExt(filepath.Ext(paths[i]))] < rank[normalizeExt(filepath.Ext(paths[j]))]
+	})
+	return paths
+}
+
+// processPath reads and decodes the configuration file at the specified path, or the standard input
+// for stdinPath, using the appropriate decoder based on the file extension.
+// With merge set the file is decoded separately and deep-merged into the configuration.
+func (c *config[T]) processPath(ctx context.Context, p string, merge bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r, ext, err := c.openPath(p)
+	if err != nil {
+		return err
+	}
+	if r == nil {
 		return nil
 	}
+	if closer, ok := r.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	if c.aead != nil {
+		if r, err = decryptFile(c.aead, r); err != nil {
+			return fmt.Errorf("error while reading %s: %w", p, err)
+		}
+	}
+
+	if _, ok := lookupFormat(ext); !ok && c.sniffFormat {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("error while reading %s: %w", p, err)
+		}
+		// strictness and warnings apply to the actual decoding only
+		if ext, err = sniffFormat[T](data, decodeOptions{coerce: c.coerce, jsonComments: c.jsonComments}); err != nil {
+			return fmt.Errorf("error while detecting format of %s: %w", p, err)
+		}
+		if c.sniffed == nil {
+			c.sniffed = map[string]string{}
+		}
+		c.sniffed[p] = ext
+		r = bytes.NewReader(data)
+	}
+
+	if !merge {
+		if err = c.decodeSource(c.cfg, func(v *T) error { return c.decodeFile(r, ext, v, p) }); err != nil {
+			return err
+		}
+	} else {
+		src := new(T)
+		if err = c.decodeSource(src, func(v *T) error { return c.decodeFile(r, ext, v, p) }); err != nil {
+			return err
+		}
+		mergeInto(c.cfg, src)
+	}
+	c.result.LoadedPaths = append(c.result.LoadedPaths, p)
+	return nil
 }
 
-// processPath reads and decodes the configuration file at the specified path
-// using the appropriate decoder based on the file extension.
-func (c *config[T]) processPath(p string) error {
+// openPath opens the configuration file at p, or reads the standard input for stdinPath,
+// and returns it with the extension selecting its decoder. Missing and empty files are recorded
+// in the result and yield a nil reader, unless skipEmpty rejects the empty file. Files outside
+// the directory set by WithConfineTo are rejected, including overlays and drop-in files.
+func (c *config[T]) openPath(p string) (io.Reader, string, error) {
+	if p == stdinPath {
+		if c.stdinFormat == "" && !c.sniffFormat {
+			return nil, "", fmt.Errorf("%w: format of stdin is not set", ErrUnsupportedExtension)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, "", fmt.Errorf("error while reading stdin: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, "", c.skipEmpty(p)
+		}
+		return bytes.NewReader(data), "." + strings.TrimPrefix(c.stdinFormat, "."), nil
+	}
+
+	if err := c.checkConfined(p); err != nil {
+		return nil, "", err
+	}
 	f, err := os.Open(p)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return nil
+			c.result.SkippedMissing = append(c.result.SkippedMissing, p)
+			return nil, "", nil
 		}
-		return err
+		return nil, "", err
 	}
-	defer func() { _ = f.Close() }()
 
 	if fi, statErr := f.Stat(); statErr == nil && fi.Size() == 0 {
-		return nil
+		_ = f.Close()
+		return nil, "", c.skipEmpty(p)
 	}
+	return f, filepath.Ext(p), nil
+}
 
-	switch ext := path.Ext(p); ext {
-	case ".json":
-		dec := json.NewDecoder(f)
-		if err = dec.Decode(c.cfg); err != nil {
-			return fmt.Errorf("error while decoding json file: %w", err)
+// skipEmpty records the empty file at p in the result. With errorOnEmpty it returns ErrEmptyConfigFile
+// instead when the file was given explicitly, i.e. by FilePathEnvName, an option or as an overlay.
+func (c *config[T]) skipEmpty(p string) error {
+	if c.errorOnEmpty && (c.explicitPaths && slices.Contains(c.paths, p) || slices.Contains(c.overlays, p)) {
+		return fmt.Errorf("%w: %s", ErrEmptyConfigFile, p)
+	}
+	c.result.SkippedEmpty = append(c.result.SkippedEmpty, p)
+	return nil
+}
+
+// decode reads configuration data from r into v using the decoder
+// registered for the file extension ext.
+func decode(r io.Reader, ext string, v any, opts decodeOptions) error {
+	f, ok := lookupFormat(ext)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedExtension, ext)
+	}
+	var err error
+	if opts.keyPolicy != nil {
+		r, err = decodeWithKeyPolicy(r, f.name, v, opts)
+	}
+	if err == nil {
+		err = f.decode(r, v, opts)
+	}
+	if err != nil {
+		if opts.source == "" {
+			return fmt.Errorf("error while decoding %s file: %w", f.name, err)
 		}
-	case ".yaml", ".yml":
-		dec := yaml.NewDecoder(f)
-		if err = dec.Decode(c.cfg); err != nil {
-			return fmt.Errorf("error while decoding yaml file: %w", err)
+		return fmt.Errorf("error while decoding %s from %s: %w", f.name, opts.source, err)
+	}
+	return nil
+}
+
+// decodeOptions returns the options for decoding the configuration data named source.
+func (c *config[T]) decodeOptions(source string) decodeOptions {
+	return decodeOptions{
+		strict:       c.strict,
+		coerce:       c.coerce,
+		jsonComments: c.jsonComments,
+		jsonMeta:     c.jsonMeta,
+		keyPolicy:    c.keyPolicy,
+		source:       source,
+		logf:         c.logf,
+	}
+}
+
+// load loads the contents of remote sources and configuration files into the configuration structure.
+// Remote sources are decoded first so that local files override them. By default only the highest-priority file with content, i.e. the last one in paths, is decoded.
+// In merge mode every file is decoded in order and deep-merged so that non-zero values
+// of later files override earlier ones. Drop-in files matching globs and then overlays are deep-merged on top afterwards.
+// Loading stops with the error of ctx before the next file once ctx is done.
+func (c *config[T]) load(ctx context.Context) error {
+	c.skipped = nil
+	for _, src := range c.remoteSources {
+		if err := c.loadSource(ctx, func() error {
+			return c.decodeSource(c.cfg, func(v *T) error { return src(ctx, v, c.decodeOptions("")) })
+		}); err != nil {
+			return err
 		}
-	case ".toml":
-		if _, err = toml.NewDecoder(f).Decode(c.cfg); err != nil {
-			return fmt.Errorf("error while decoding toml file: %w", err)
+	}
+
+	if err := c.loadPaths(ctx); err != nil {
+		return err
+	}
+
+	for _, pattern := range c.globs {
+		if err := c.loadGlob(ctx, pattern); err != nil {
+			return err
+		}
+	}
+
+	if c.requireOverlays {
+		if err := c.loadSource(ctx, c.checkOverlays); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.overlays {
+		if _, ok := lookupFormat(filepath.Ext(p)); !ok && c.ignoreUnsupported && !c.sniffFormat {
+			c.logf("INFO: skipping config file %q with unsupported extension", p)
+			continue
+		}
+		if err := c.loadSource(ctx, func() error { return c.processPath(ctx, p, true) }); err != nil {
+			return err
+		}
+	}
+
+	c.sources = nil
+	for _, p := range c.paths {
+		if slices.Contains(c.result.LoadedPaths, p) || slices.Contains(c.result.SkippedEmpty, p) {
+			c.sources = append(c.sources, p)
 		}
-	default:
-		return fmt.Errorf("unsupported file extension: %s", ext)
+	}
+
+	warnDeprecated(reflect.ValueOf(c.cfg).Elem(), "", c.logf)
+	if len(c.skipped) > 0 {
+		return skippedSourcesError(c.skipped)
 	}
 	return nil
 }
 
-// load processes all configuration file paths and loads their contents
-// into the configuration structure.
-func (c *config[T]) load() error {
-	for _, p := range c.paths {
-		if err := c.processPath(p); err != nil {
+// decodeSource calls decode, which decodes a single source, with a deep copy of v and stores the copy in v
+// once decode succeeds, so that a failing source leaves no partially decoded values behind, not even
+// in maps and slices. Fields tagged with the encrypted modifier that decode sets are decrypted, while
+// the values v already holds are left as they are.
+func (c *config[T]) decodeSource(v *T, decode func(v *T) error) error {
+	dst := deepCopy(v)
+	var before map[string]string
+	if c.aead != nil {
+		before = encryptedValues(dst)
+	}
+	if err := decode(dst); err != nil {
+		return err
+	}
+	if c.aead != nil {
+		if c.sealed == nil {
+			c.sealed = map[string]string{}
+		}
+		if err := decryptFields(c.aead, dst, before, c.sealed); err != nil {
 			return err
 		}
 	}
+	*v = *dst
 	return nil
 }
 
-// setConfigPathForOneFile sets a single configuration file path and creates the file
-// if it doesn't exist.
-func (c *config[T]) setConfigPathForOneFile(configPath string) error {
-	if fileExists(configPath) {
-		c.paths = []string{configPath}
-		return nil
+// checkOverlays returns a MissingFilesError naming every overlay file that doesn't exist.
+func (c *config[T]) checkOverlays() error {
+	var missing []string
+	for _, p := range c.overlays {
+		if !fileExists(p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingFilesError{Paths: missing}
 	}
+	return nil
+}
 
-	f, err := os.Create(configPath)
+// loadSource calls load, which decodes a single source into the configuration with decodeSource.
+// With continueOnError a failing source is skipped: the configuration is left as it was, the error is logged
+// and recorded, and nil is returned so that loading goes on. Cancellation of ctx is never skipped.
+func (c *config[T]) loadSource(ctx context.Context, load func() error) error {
+	if !c.continueOnError {
+		return load()
+	}
+
+	err := load()
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	c.logf("WARNING: skipping config source; err=%v", err)
+	c.skipped = append(c.skipped, err)
+	return nil
+}
+
+// loadGlob merges the files matching pattern in lexical order.
+// Directories and files with unsupported extensions are skipped.
+func (c *config[T]) loadGlob(ctx context.Context, pattern string) error {
+	matches, err := filepath.Glob(pattern)
 	if err != nil {
+		return fmt.Errorf("error while matching %s: %w", pattern, err)
+	}
+	sort.Strings(matches)
+
+	for _, p := range matches {
+		if _, ok := lookupFormat(filepath.Ext(p)); !ok || !fileExists(p) {
+			continue
+		}
+		if err = c.loadSource(ctx, func() error { return c.processPath(ctx, p, true) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPaths loads the discovered configuration files according to the merge mode.
+// Files listed in FilePathEnvName are always merged.
+func (c *config[T]) loadPaths(ctx context.Context) error {
+	if c.mergeAll || c.layerPaths {
+		for _, p := range c.paths {
+			if err := c.loadSource(ctx, func() error { return c.processPath(ctx, p, true) }); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := len(c.paths) - 1; i >= 0; i-- {
+		loaded := len(c.result.LoadedPaths)
+		if err := c.loadSource(ctx, func() error { return c.processPath(ctx, c.paths[i], false) }); err != nil {
+			return err
+		}
+		if len(c.result.LoadedPaths) > loaded {
+			return nil
+		}
+	}
+	return nil
+}
+
+// setConfigPathForOneFile adds a single configuration file path and creates the file
+// if it doesn't exist. Missing files with unsupported extensions are rejected before anything is created.
+func (c *config[T]) setConfigPathForOneFile(configPath string) error {
+	if err := c.checkConfined(configPath); err != nil {
 		return err
 	}
-	_ = f.Close()
+	if fileExists(configPath) || c.noCreate {
+		c.paths = append(c.paths, configPath)
+		return nil
+	}
+
+	if _, ok := lookupFormat(filepath.Ext(configPath)); !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedExtension, configPath)
+	}
 
-	if err = c.writeToFile(f.Name()); err != nil {
+	// the file appears only once its contents are written, so a failed write leaves nothing behind
+	if err := c.writeToFile(configPath); err != nil {
 		return err
 	}
+	if fileExists(configPath) {
+		c.result.Created = true
+		c.result.CreatedPaths = append(c.result.CreatedPaths, configPath)
+	}
+	c.paths = append(c.paths, configPath)
 	return nil
 }
 
+// unchanged reports whether current, the contents of a file, matches data, the contents to write,
+// or plain, the contents without the write header. Files encrypted as a whole are compared decrypted.
+func (c *config[T]) unchanged(current, data, plain []byte, ext string) bool {
+	if c.aead != nil && isEncryptedFile(current) {
+		plaintext, err := openValue(c.aead, string(current[len(encryptedFileHeader):]))
+		if err != nil {
+			return false
+		}
+		current = plaintext
+	}
+	return bytes.Equal(current, data) || c.writeHeader != nil && bytes.Equal(c.stripWriteHeader(current, ext), plain)
+}
+
 // writeToFile writes the configuration data to a file at the specified path
-// using a temporary file for atomic writes.
+// using a temporary file in the same directory for atomic writes.
+// Permissions of an existing file are preserved, new files get fileMode; with yamlRoundTrip the comments and
+// anchors of an existing YAML file as well. With skipUnchanged the file
+// is left untouched when its contents already match, with backupSuffix the
+// existing file is copied aside before it is replaced, with durable the file and its
+// directory are synced around the rename. With dryRun nothing is
+// written and the write is recorded in the result instead. Targets and backups outside the directory
+// set by WithConfineTo are rejected.
 func (c *config[T]) writeToFile(fPath string) error {
-	f, err := createTempFile("config*" + path.Ext(fPath))
+	if err := c.checkConfined(fPath); err != nil {
+		return err
+	}
+	if c.backupSuffix != "" {
+		if err := c.checkConfined(fPath + c.backupSuffix); err != nil {
+			return err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.encodeFile(buf, fPath); err != nil {
+		return err
+	}
+
+	plain := buf.Bytes()
+	if c.writeHeader != nil {
+		data, err := c.addWriteHeader(plain, c.fileExt(fPath))
+		if err != nil {
+			return err
+		}
+		buf = bytes.NewBuffer(data)
+	}
+
+	if c.skipUnchanged {
+		// the time of the write in the header doesn't make the file changed, nor does the nonce of an encrypted file
+		if current, readErr := os.ReadFile(fPath); readErr == nil && c.unchanged(current, buf.Bytes(), plain, c.fileExt(fPath)) {
+			c.logf("INFO: config file %q is unchanged, skipping write", fPath)
+			return nil
+		}
+	}
+
+	if c.aead != nil {
+		if current, readErr := os.ReadFile(fPath); readErr == nil && isEncryptedFile(current) {
+			data, err := encryptFile(c.aead, buf.Bytes())
+			if err != nil {
+				return err
+			}
+			buf = bytes.NewBuffer(data)
+		}
+	}
+
+	if c.dryRun {
+		c.logf("INFO: dry run, would write %d bytes to %q:\n%s", buf.Len(), fPath, buf.Bytes())
+		c.resultMu.Lock()
+		c.result.PlannedWrites = append(c.result.PlannedWrites, PlannedWrite{Path: fPath, Data: buf.Bytes()})
+		c.resultMu.Unlock()
+		return nil
+	}
+
+	tempDir := c.tempDir
+	if tempDir == "" {
+		tempDir = filepath.Dir(fPath)
+	}
+	f, err := createTempFile(tempDir, "config*"+filepath.Ext(fPath))
 	if err != nil {
 		return err
 	}
@@ -221,38 +1166,105 @@ func (c *config[T]) writeToFile(fPath string) error {
 		_ = os.Remove(f.Name())
 	}()
 
-	if err = c.encodeToFile(f); err != nil {
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	mode := c.fileMode
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	fi, statErr := os.Stat(fPath)
+	if statErr == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = f.Chmod(mode); err != nil {
 		return err
 	}
+	if c.durable {
+		if err = f.Sync(); err != nil {
+			return fmt.Errorf("error while syncing temp file for %s: %w", fPath, err)
+		}
+	}
+
+	if c.backupSuffix != "" && statErr == nil {
+		if err = backupFile(fPath, fPath+c.backupSuffix, mode); err != nil {
+			return err
+		}
+	}
 
 	if err = os.Rename(f.Name(), fPath); err != nil {
-		log.Printf("ERROR: os.Rename(%q, %q); err=%v", f.Name(), fPath, err)
-		return nil
+		return fmt.Errorf("error while renaming temp file to %s: %w", fPath, err)
+	}
+
+	if c.durable {
+		if err = syncDir(filepath.Dir(fPath)); err != nil {
+			return fmt.Errorf("error while syncing directory of %s: %w", fPath, err)
+		}
 	}
 
 	return nil
 }
 
+// syncDir flushes the directory entries of dir to disk, making a rename into it durable.
+// Directories can't be synced on Windows, where it does nothing.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
+
 // writeToFileAsync asynchronously writes configuration data to a file
-// and reports any errors through the error channel.
-func (c *config[T]) writeToFileAsync(wg *sync.WaitGroup, fPath string, errCh chan<- error) {
+// and reports any errors through the error channel. It holds a slot of sem while writing.
+func (c *config[T]) writeToFileAsync(wg *sync.WaitGroup, sem chan struct{}, fPath string, errCh chan<- error) {
 	defer wg.Done()
+	defer func() { <-sem }()
 	err := c.writeToFile(fPath)
 	if err != nil {
 		errCh <- err
 	}
 }
 
-// writeToFiles concurrently writes configuration data to all configured paths
-// and aggregates any errors that occur during the process.
+// writeToFiles writes configuration data to the configuration files that were loaded,
+// or only to the primary one with syncPrimaryOnly, using writeToPaths. Files found but not loaded,
+// e.g. lower-priority format variants, are left alone.
 func (c *config[T]) writeToFiles() error {
+	paths := c.sources
+	if c.syncPrimaryOnly {
+		paths = nil
+		if p := c.primaryPath(); p != "" {
+			paths = []string{p}
+		}
+	}
+
+	// the standard input can't be written back
+	paths = slices.DeleteFunc(slices.Clone(paths), func(p string) bool { return p == stdinPath })
+	return c.writeToPaths(paths)
+}
+
+// writeToPaths concurrently writes configuration data to the paths and aggregates any errors
+// that occur during the process. At most writeConcurrency files are written at once.
+func (c *config[T]) writeToPaths(paths []string) error {
+	limit := c.writeConcurrency
+	if limit == 0 {
+		limit = defaultWriteConcurrency
+	}
+
 	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, limit)
 
-	wg.Add(len(c.paths))
-	errCh := make(chan error, len(c.paths))
+	wg.Add(len(paths))
+	errCh := make(chan error, len(paths))
 
-	for _, fPath := range c.paths {
-		go c.writeToFileAsync(&wg, fPath, errCh)
+	for _, fPath := range paths {
+		sem <- struct{}{}
+		go c.writeToFileAsync(&wg, sem, fPath, errCh)
 	}
 	wg.Wait()
 	close(errCh)
@@ -266,11 +1278,24 @@ func (c *config[T]) writeToFiles() error {
 	return resultErr
 }
 
-// createTempFile creates a temporary file with the specified extension in the system's temporary directory.
+// backupFile copies the file at src to dst, replacing dst if it exists.
+func backupFile(src, dst string, mode os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("error while reading %s for backup: %w", src, err)
+	}
+	if err = os.WriteFile(dst, data, mode); err != nil {
+		return fmt.Errorf("error while writing backup %s: %w", dst, err)
+	}
+	return nil
+}
+
+// createTempFile creates a temporary file with the specified name pattern in the directory dir.
 // It returns a pointer to the created file and any error encountered during the creation process.
-// The ext parameter should include the file extension with the dot prefix (e.g., ".json", ".yaml").
-func createTempFile(ext string) (*os.File, error) {
-	f, err := os.CreateTemp(os.TempDir(), ext)
+// The pattern should end with the file extension with the dot prefix (e.g., "config*.json"),
+// dir should be on the same filesystem as the file the temporary one is renamed to.
+func createTempFile(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
 	if err != nil {
 		return nil, err
 	}
@@ -286,22 +1311,12 @@ func fileExists(path string) bool {
 }
 
 // getEncoderForFile returns encoder to io writer based on extension
-func getEncoderForFile(ext string, f io.Writer) (encoder, error) {
-	switch ext {
-	case ".json":
-		enc := json.NewEncoder(f)
-		enc.SetIndent("", "  ")
-		return enc, nil
-	case ".toml":
-		enc := toml.NewEncoder(f)
-		return enc, nil
-	case ".yaml", ".yml":
-		enc := yaml.NewEncoder(f)
-		enc.SetIndent(2)
-		return enc, nil
-	default:
-		return nil, fmt.Errorf("unsupported file extension: %s", ext)
+func getEncoderForFile(ext string, f io.Writer, opts EncoderOptions) (encoder, error) {
+	format, ok := lookupFormat(ext)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedExtension, ext)
 	}
+	return format.newEncoder(f, opts), nil
 }
 
 // getExistingPaths returns a slice of existing file paths from the provided paths
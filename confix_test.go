@@ -1,13 +1,21 @@
 package confix
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
@@ -35,7 +43,7 @@ func (unparsableConfig) UnmarshalJSON([]byte) error   { return errUnparsable }
 func (unparsableConfig) MarshalJSON() ([]byte, error) { return nil, errUnparsable }
 
 func TestNew_UnparsableConfig(t *testing.T) {
-	cfg, err := createTempFile("config*.json")
+	cfg, err := createTempFile(os.TempDir(), "config*.json")
 	defer func() {
 		require.NoError(t, os.Remove(cfg.Name()))
 	}()
@@ -48,6 +56,21 @@ func TestNew_UnparsableConfig(t *testing.T) {
 	}
 }
 
+func TestNew_UnparsableConfigMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	fpath := path.Join(dir, "config.json")
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	err := New(new(unparsableConfig))
+	assert.ErrorIs(t, err, errUnparsable)
+	assert.NoFileExists(t, fpath)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
 func TestFileExists(t *testing.T) {
 	t.Run("positive", func(t *testing.T) {
 		f, err := os.CreateTemp(os.TempDir(), generateRandom(t, 20))
@@ -122,10 +145,11 @@ func TestGetConfigPaths(t *testing.T) {
 		require.NoError(t, os.Unsetenv(FilePathEnvName))
 		require.NoError(t, os.Setenv(DirEnvName, dir))
 		pathsExpected := []string{
-			path.Join(dir, jsonConfigFileName),
-			path.Join(dir, tomlConfigFileName),
-			path.Join(dir, ymlConfigFileName),
-			path.Join(dir, yamlConfigFileName),
+			path.Join(dir, defaultConfigBaseName+jsonExt),
+			path.Join(dir, defaultConfigBaseName+tomlExt),
+			path.Join(dir, defaultConfigBaseName+ymlExt),
+			path.Join(dir, defaultConfigBaseName+yamlExt),
+			path.Join(dir, defaultConfigBaseName+iniExt),
 		}
 		for _, p := range pathsExpected {
 			f, err := os.Create(p)
@@ -138,13 +162,30 @@ func TestGetConfigPaths(t *testing.T) {
 				assert.NoError(t, os.Remove(p))
 			}
 		}()
-		cfg := new(config[testConfig])
+		cfg := &config[testConfig]{settings: newSettings()}
+		err := cfg.getConfigPaths()
+		assert.NoError(t, err)
+		assert.Equal(t, pathsExpected, cfg.paths)
+	})
+	t.Run("positive: custom base name", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		t.Setenv(BaseNameEnvName, "settings")
+		pathsExpected := []string{
+			path.Join(dir, "settings"+jsonExt),
+			path.Join(dir, "settings"+yamlExt),
+		}
+		for _, p := range append(pathsExpected, path.Join(dir, defaultConfigBaseName+tomlExt)) {
+			require.NoError(t, os.WriteFile(p, nil, 0o600))
+		}
+		cfg := &config[testConfig]{settings: newSettings()}
 		err := cfg.getConfigPaths()
 		assert.NoError(t, err)
 		assert.Equal(t, pathsExpected, cfg.paths)
 	})
 	t.Run("positive: config file", func(t *testing.T) {
-		f, err := os.CreateTemp(os.TempDir(), jsonConfigFileName)
+		f, err := os.CreateTemp(os.TempDir(), defaultConfigBaseName+jsonExt)
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
 		defer func() {
@@ -159,7 +200,7 @@ func TestGetConfigPaths(t *testing.T) {
 			f.Name(),
 		}
 
-		cfg := new(config[testConfig])
+		cfg := &config[testConfig]{settings: newSettings()}
 		err = cfg.getConfigPaths()
 		assert.NoError(t, err)
 		assert.Equal(t, pathsExpected, cfg.paths)
@@ -169,17 +210,19 @@ func TestGetConfigPaths(t *testing.T) {
 		require.NoError(t, os.Unsetenv(FilePathEnvName))
 
 		pathsExpected := getExistingPaths(
-			path.Join(currentDir, tomlConfigFileName),
-			path.Join(currentDir, jsonConfigFileName),
-			path.Join(currentDir, ymlConfigFileName),
-			path.Join(currentDir, yamlConfigFileName),
-			tomlConfigFileName,
-			jsonConfigFileName,
-			ymlConfigFileName,
-			yamlConfigFileName,
+			path.Join(executableDir(), defaultConfigBaseName+tomlExt),
+			path.Join(executableDir(), defaultConfigBaseName+jsonExt),
+			path.Join(executableDir(), defaultConfigBaseName+ymlExt),
+			path.Join(executableDir(), defaultConfigBaseName+yamlExt),
+			path.Join(executableDir(), defaultConfigBaseName+iniExt),
+			defaultConfigBaseName+tomlExt,
+			defaultConfigBaseName+jsonExt,
+			defaultConfigBaseName+ymlExt,
+			defaultConfigBaseName+yamlExt,
+			defaultConfigBaseName+iniExt,
 		)
 
-		cfg := new(config[testConfig])
+		cfg := &config[testConfig]{settings: newSettings()}
 		err := cfg.getConfigPaths()
 		assert.NoError(t, err)
 		assert.Equal(t, pathsExpected, cfg.paths)
@@ -194,28 +237,33 @@ func TestGetEncoderForFile(t *testing.T) {
 		assert.NoError(t, os.Remove(f.Name()))
 	}()
 	var enc encoder
-	enc, err = getEncoderForFile(".json", f)
+	enc, err = getEncoderForFile(".json", f, EncoderOptions{})
 	assert.NoError(t, err)
 	if assert.NotNil(t, enc) {
 		assert.IsType(t, enc, &json.Encoder{})
 	}
-	enc, err = getEncoderForFile(".yml", f)
+	enc, err = getEncoderForFile(".yml", f, EncoderOptions{})
 	assert.NoError(t, err)
 	if assert.NotNil(t, enc) {
 		assert.IsType(t, enc, &yaml.Encoder{})
 	}
-	enc, err = getEncoderForFile(".yaml", f)
+	enc, err = getEncoderForFile(".yaml", f, EncoderOptions{})
 	assert.NoError(t, err)
 	if assert.NotNil(t, enc) {
 		assert.IsType(t, enc, &yaml.Encoder{})
 	}
-	enc, err = getEncoderForFile(".toml", f)
+	enc, err = getEncoderForFile(".toml", f, EncoderOptions{})
 	assert.NoError(t, err)
 	if assert.NotNil(t, enc) {
 		assert.IsType(t, enc, &toml.Encoder{})
 	}
-	enc, err = getEncoderForFile(".unknown", f)
-	assert.Error(t, err)
+	enc, err = getEncoderForFile(".ini", f, EncoderOptions{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, enc) {
+		assert.IsType(t, enc, &iniEncoder{})
+	}
+	enc, err = getEncoderForFile(".unknown", f, EncoderOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedExtension)
 }
 
 func TestWriteToFile(t *testing.T) {
@@ -361,8 +409,9 @@ func TestWriteToFiles(t *testing.T) {
 		require.NoError(t, err)
 
 		c := &config[testConfig]{
-			cfg:   cfg,
-			paths: paths,
+			cfg:     cfg,
+			paths:   paths,
+			sources: paths,
 		}
 
 		err = c.writeToFile(name)
@@ -398,8 +447,9 @@ func TestWriteToFiles(t *testing.T) {
 
 	t.Run("negative", func(t *testing.T) {
 		c := &config[testConfig]{
-			cfg:   cfg,
-			paths: []string{"unknown"},
+			cfg:     cfg,
+			paths:   []string{"unknown"},
+			sources: []string{"unknown"},
 		}
 
 		err := c.writeToFiles()
@@ -546,7 +596,7 @@ func TestNew(t *testing.T) {
 
 func TestCreateTempFile(t *testing.T) {
 	t.Run("positive", func(t *testing.T) {
-		f, err := createTempFile(generateRandom(t, 100))
+		f, err := createTempFile(os.TempDir(), generateRandom(t, 100))
 		assert.NotNil(t, f)
 		assert.NoError(t, err)
 		assert.FileExists(t, f.Name())
@@ -554,7 +604,7 @@ func TestCreateTempFile(t *testing.T) {
 	})
 	t.Run("negative", func(t *testing.T) {
 		n := generateRandom(t, 100)
-		f1, err := createTempFile(n + "\\///")
+		f1, err := createTempFile(os.TempDir(), n+"\\///")
 		assert.Nil(t, f1)
 		assert.Error(t, err)
 	})
@@ -566,3 +616,739 @@ func TestWriteToFile_Negative(t *testing.T) {
 	err := c.writeToFile("\\///.,")
 	assert.Error(t, err)
 }
+
+func TestNewWithResult(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), nil, 0o600))
+
+		cfg := new(testConfig)
+		res, err := NewWithResult(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "json", cfg.A)
+		assert.Equal(t, []string{path.Join(dir, "config.json")}, res.LoadedPaths)
+		assert.Equal(t, []string{path.Join(dir, "config.yaml")}, res.SkippedEmpty)
+		assert.Empty(t, res.SkippedMissing)
+		assert.False(t, res.Created)
+	})
+	t.Run("positive: created", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		res, err := NewWithResult(&testConfig{A: "default"})
+		require.NoError(t, err)
+		assert.True(t, res.Created)
+		assert.Equal(t, []string{fpath}, res.CreatedPaths)
+
+		// the file exists on the next run
+		res, err = NewWithResult(new(testConfig))
+		require.NoError(t, err)
+		assert.False(t, res.Created)
+		assert.Empty(t, res.CreatedPaths)
+
+		// nothing is created in a dry run
+		t.Setenv(FilePathEnvName, path.Join(t.TempDir(), "config.json"))
+		res, err = NewWithResult(new(testConfig), WithDryRun[testConfig]())
+		require.NoError(t, err)
+		assert.False(t, res.Created)
+	})
+	t.Run("positive: missing", func(t *testing.T) {
+		missing := path.Join(t.TempDir(), "config.json")
+		c := &config[testConfig]{
+			cfg:   new(testConfig),
+			paths: []string{missing},
+		}
+		require.NoError(t, c.load(context.Background()))
+		assert.Equal(t, []string{missing}, c.result.SkippedMissing)
+		assert.Empty(t, c.result.LoadedPaths)
+	})
+	t.Run("negative", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": `), 0o600))
+
+		res, err := NewWithResult(new(testConfig))
+		assert.Error(t, err)
+		assert.Equal(t, Result{}, res)
+	})
+}
+
+func TestNewFromReader(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		for format, data := range map[string]string{
+			"json": `{"a": "value"}`,
+			"yaml": `a: value`,
+			"yml":  `a: value`,
+			"toml": `a = "value"`,
+			"ini":  `a = value`,
+		} {
+			t.Run(format, func(t *testing.T) {
+				cfg := new(testConfig)
+				require.NoError(t, NewFromReader(cfg, strings.NewReader(data), format))
+				assert.Equal(t, "value", cfg.A)
+			})
+		}
+	})
+	t.Run("positive: options", func(t *testing.T) {
+		var validated bool
+		cfg := new(testConfig)
+		err := NewFromReader(cfg, strings.NewReader(`{"a": "value"}`), ".json",
+			WithValidation(func(cfg *testConfig) error {
+				validated = cfg.A == "value"
+				return nil
+			}))
+		require.NoError(t, err)
+		assert.True(t, validated)
+	})
+	t.Run("positive: decryption", func(t *testing.T) {
+		password, err := EncryptValue(testKey, "pa55")
+		require.NoError(t, err)
+		data := `{"user": "admin", "password": "` + password + `"}`
+
+		cfg := new(encryptedConfig)
+		require.NoError(t, NewFromReader(cfg, strings.NewReader(data), "json", WithDecryption[encryptedConfig](testKey)))
+		assert.Equal(t, "admin", cfg.User)
+		assert.Equal(t, "pa55", cfg.Password)
+
+		stream, err := EncryptFile(testKey, []byte(data))
+		require.NoError(t, err)
+		cfg = new(encryptedConfig)
+		require.NoError(t, NewFromReader(cfg, bytes.NewReader(stream), "json", WithDecryption[encryptedConfig](testKey)))
+		assert.Equal(t, "admin", cfg.User)
+		assert.Equal(t, "pa55", cfg.Password)
+	})
+	t.Run("positive: overlays", func(t *testing.T) {
+		overlay := path.Join(t.TempDir(), "local.yaml")
+		require.NoError(t, os.WriteFile(overlay, []byte("b: overlay\n"), 0o600))
+
+		type overlayConfig struct {
+			A string `json:"a" yaml:"a"`
+			B string `json:"b" yaml:"b"`
+		}
+		cfg := new(overlayConfig)
+		require.NoError(t, NewFromReader(cfg, strings.NewReader(`{"a": "value", "b": "stream"}`), "json",
+			WithOverlay[overlayConfig](overlay)))
+		assert.Equal(t, "value", cfg.A)
+		assert.Equal(t, "overlay", cfg.B)
+	})
+	t.Run("negative: unsupported format", func(t *testing.T) {
+		err := NewFromReader(new(testConfig), strings.NewReader(`a: value`), "xml")
+		assert.Error(t, err)
+	})
+	t.Run("negative: invalid data", func(t *testing.T) {
+		err := NewFromReader(new(testConfig), strings.NewReader(`{"a": `), "json")
+		assert.Error(t, err)
+	})
+}
+
+func TestDump(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		for format, expected := range map[string]string{
+			"json":  "{\n  \"a\": \"value\"\n}\n",
+			".yaml": "a: value\n",
+			"yml":   "a: value\n",
+			"TOML":  "a = \"value\"\n",
+			"ini":   "a = value\n",
+		} {
+			t.Run(format, func(t *testing.T) {
+				buf := &bytes.Buffer{}
+				require.NoError(t, Dump(&testConfig{A: "value"}, format, buf))
+				assert.Equal(t, expected, buf.String())
+
+				// the dump is read back into the same config
+				cfg := new(testConfig)
+				require.NoError(t, NewFromReader(cfg, buf, strings.ToLower(format)))
+				assert.Equal(t, "value", cfg.A)
+			})
+		}
+	})
+	t.Run("negative: unsupported format", func(t *testing.T) {
+		err := Dump(&testConfig{A: "value"}, "xml", io.Discard)
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		for ext, expected := range map[string]string{
+			".json": "{\n  \"a\": \"value\"\n}\n",
+			"yaml":  "a: value\n",
+			".TOML": "a = \"value\"\n",
+			".ini":  "a = value\n",
+		} {
+			t.Run(ext, func(t *testing.T) {
+				data, err := Marshal(&testConfig{A: "value"}, ext)
+				require.NoError(t, err)
+				assert.Equal(t, expected, string(data))
+			})
+		}
+	})
+	t.Run("negative: unsupported extension", func(t *testing.T) {
+		_, err := Marshal(&testConfig{A: "value"}, ".xml")
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		for ext, data := range map[string]string{
+			".json": `{"a": "value"}`,
+			"yaml":  "a: value\n",
+			".TOML": `a = "value"`,
+			".ini":  "a = value\n",
+		} {
+			t.Run(ext, func(t *testing.T) {
+				cfg := new(testConfig)
+				require.NoError(t, Unmarshal([]byte(data), ext, cfg))
+				assert.Equal(t, "value", cfg.A)
+			})
+		}
+	})
+	t.Run("positive: round trip", func(t *testing.T) {
+		data, err := Marshal(&testConfig{A: "value"}, ".yaml")
+		require.NoError(t, err)
+		cfg := new(testConfig)
+		require.NoError(t, Unmarshal(data, ".yaml", cfg))
+		assert.Equal(t, &testConfig{A: "value"}, cfg)
+	})
+	t.Run("negative: strict decoding", func(t *testing.T) {
+		data := []byte(`{"a": "value", "typo": 1}`)
+		require.NoError(t, Unmarshal(data, ".json", new(testConfig)))
+
+		err := Unmarshal(data, ".json", new(testConfig), WithStrictDecoding[testConfig]())
+		assert.ErrorContains(t, err, "typo")
+	})
+	t.Run("negative: unsupported extension", func(t *testing.T) {
+		err := Unmarshal([]byte("<a/>"), ".xml", new(testConfig))
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+}
+
+func TestLoadInto(t *testing.T) {
+	type loadConfig struct {
+		A string `json:"a" default:"default"`
+		B string `json:"b"`
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(DirEnvName))
+
+	t.Run("positive", func(t *testing.T) {
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"b": "b"}`), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(loadConfig)
+		require.NoError(t, LoadInto(cfg))
+		assert.Equal(t, &loadConfig{B: "b"}, cfg)
+	})
+	t.Run("positive: missing file is not created", func(t *testing.T) {
+		fpath := path.Join(dir, "missing.json")
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(loadConfig)
+		require.NoError(t, LoadInto(cfg))
+		assert.Equal(t, &loadConfig{}, cfg)
+		assert.NoFileExists(t, fpath)
+	})
+	t.Run("negative", func(t *testing.T) {
+		fpath := path.Join(dir, "broken.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"b": `), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		assert.ErrorContains(t, LoadInto(new(loadConfig)), fpath)
+	})
+}
+
+func TestWriteToFile_FileMode(t *testing.T) {
+	t.Run("positive: existing file keeps mode", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, nil, 0o600))
+		require.NoError(t, os.Chmod(fpath, 0o640))
+
+		c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+		require.NoError(t, c.writeToFile(fpath))
+
+		fi, err := os.Stat(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+	})
+	t.Run("positive: new file gets default mode", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+
+		c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+		require.NoError(t, c.writeToFile(fpath))
+
+		fi, err := os.Stat(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultFileMode, fi.Mode().Perm())
+	})
+	t.Run("positive: new file gets configured mode", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		require.NoError(t, New(&testConfig{A: "a"}, WithFileMode[testConfig](0o640)))
+
+		fi, err := os.Stat(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+	})
+}
+
+func TestWriteToFile_Durable(t *testing.T) {
+	dir := t.TempDir()
+	fpath := path.Join(dir, "config.json")
+
+	c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+	require.NoError(t, WithDurableWrites[testConfig]().apply(c))
+	require.NoError(t, c.writeToFile(fpath))
+
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": "a"}`, string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, syncDir(path.Join(dir, "missing")))
+}
+
+func TestWriteToFile_SameDirectory(t *testing.T) {
+	dir := t.TempDir()
+	fpath := path.Join(dir, "config.yaml")
+
+	c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+	require.NoError(t, c.writeToFile(fpath))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "config.yaml", entries[0].Name())
+	}
+}
+
+func TestWriteToFile_TempDir(t *testing.T) {
+	dir := t.TempDir()
+	tempDir := filepath.Join(dir, "tmp")
+	fpath := filepath.Join(dir, "config.json")
+
+	c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+	require.NoError(t, WithTempDir[testConfig](tempDir).apply(c))
+
+	// temp files are created in the configured directory only
+	assert.ErrorContains(t, c.writeToFile(fpath), tempDir)
+	assert.NoFileExists(t, fpath)
+
+	require.NoError(t, os.Mkdir(tempDir, 0o700))
+	require.NoError(t, c.writeToFile(fpath))
+	assert.FileExists(t, fpath)
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestWriteToFile_RenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	// a non-empty directory at the target path can't be replaced by rename
+	fpath := path.Join(dir, "config.json")
+	require.NoError(t, os.Mkdir(fpath, 0o700))
+	require.NoError(t, os.WriteFile(path.Join(fpath, "keep"), nil, 0o600))
+
+	c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+	err := c.writeToFile(fpath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), fpath)
+		var linkErr *os.LinkError
+		assert.ErrorAs(t, err, &linkErr)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file must be removed")
+
+	err = WithWritingConfigToFile[testConfig](fpath).apply(c)
+	assert.Error(t, err)
+}
+
+func TestNewContext(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+
+	t.Run("positive", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, NewContext(context.Background(), cfg))
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("negative: cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		cfg := new(testConfig)
+		err := NewContext(ctx, cfg)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, cfg.A)
+	})
+	t.Run("negative: deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		err := NewContext(ctx, new(testConfig))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestErrUnsupportedExtension(t *testing.T) {
+	t.Run("decode", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.xml")
+		require.NoError(t, os.WriteFile(fpath, []byte("<a/>"), 0o600))
+		c := &config[testConfig]{
+			cfg:   new(testConfig),
+			paths: []string{fpath},
+		}
+		err := c.load(context.Background())
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+	t.Run("encode", func(t *testing.T) {
+		c := &config[testConfig]{cfg: new(testConfig)}
+		err := c.writeToFile(path.Join(t.TempDir(), "config.xml"))
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+	t.Run("reader", func(t *testing.T) {
+		err := NewFromReader(new(testConfig), strings.NewReader("<a/>"), "xml")
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+	t.Run("config file path", func(t *testing.T) {
+		dir := t.TempDir()
+		fpath := path.Join(dir, "config.conf")
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, path.Join(dir, "config.json")+string(os.PathListSeparator)+fpath)
+
+		err := New(new(testConfig))
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+		assert.ErrorContains(t, err, fpath)
+		assert.NoFileExists(t, fpath)
+	})
+}
+
+func TestExecutableDir(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Dir(exe), executableDir())
+
+	c := &config[testConfig]{settings: newSettings()}
+	assert.Equal(t, executableDir(), c.baseDir)
+}
+
+func TestWithBaseDir(t *testing.T) {
+	t.Setenv(FilePathEnvName, "")
+	t.Setenv(DirEnvName, "")
+
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for i, dir := range dirs {
+		data := fmt.Sprintf(`{"a": "base%d"}`, i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0o600))
+	}
+
+	t.Run("positive", func(t *testing.T) {
+		for i, dir := range dirs {
+			t.Run(dir, func(t *testing.T) {
+				t.Parallel()
+				cfg := &testConfig{}
+				res, err := NewWithResult(cfg, WithBaseDir[testConfig](dir))
+				require.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("base%d", i), cfg.A)
+				assert.Equal(t, []string{filepath.Join(dir, "config.json")}, res.LoadedPaths)
+			})
+		}
+	})
+	t.Run("positive: disabled", func(t *testing.T) {
+		c := &config[testConfig]{settings: newSettings(), cfg: &testConfig{}}
+		require.NoError(t, WithBaseDir[testConfig]("").apply(c))
+		require.NoError(t, c.getConfigPaths())
+		assert.NotContains(t, c.candidates, filepath.Join(executableDir(), "config.json"))
+		assert.Contains(t, c.candidates, "config.json")
+	})
+}
+
+func TestUppercaseExtensions(t *testing.T) {
+	for ext, data := range map[string]string{
+		".JSON": `{"a": "upper"}`,
+		".YML":  "a: upper\n",
+		".YAML": "a: upper\n",
+		".TOML": `a = "upper"`,
+		".INI":  "a = upper\n",
+	} {
+		t.Run(ext, func(t *testing.T) {
+			fpath := path.Join(t.TempDir(), "Config"+ext)
+			require.NoError(t, os.WriteFile(fpath, []byte(data), 0o600))
+
+			c := &config[testConfig]{cfg: &testConfig{}, paths: []string{fpath}}
+			require.NoError(t, c.load(context.Background()))
+			assert.Equal(t, "upper", c.cfg.A)
+
+			enc, err := getEncoderForFile(ext, io.Discard, EncoderOptions{})
+			assert.NoError(t, err)
+			assert.NotNil(t, enc)
+
+			c.cfg.A = "written"
+			require.NoError(t, c.writeToFile(fpath))
+			c.cfg.A = ""
+			require.NoError(t, c.load(context.Background()))
+			assert.Equal(t, "written", c.cfg.A)
+		})
+	}
+}
+
+func TestWriteToFileSortsMapKeys(t *testing.T) {
+	type mapConfig struct {
+		Labels map[string]int `json:"labels" yaml:"labels" toml:"labels"`
+	}
+	labels := map[string]int{}
+	for i := 0; i < 20; i++ {
+		labels[string(rune('a'+i))] = i
+	}
+
+	for _, ext := range []string{".json", ".yaml", ".toml"} {
+		t.Run(ext, func(t *testing.T) {
+			fpath := path.Join(t.TempDir(), "config"+ext)
+			c := &config[mapConfig]{cfg: &mapConfig{Labels: labels}}
+
+			require.NoError(t, c.writeToFile(fpath))
+			first, err := os.ReadFile(fpath)
+			require.NoError(t, err)
+
+			for i := 0; i < 10; i++ {
+				require.NoError(t, c.writeToFile(fpath))
+				data, err := os.ReadFile(fpath)
+				require.NoError(t, err)
+				require.Equal(t, string(first), string(data))
+			}
+		})
+	}
+}
+
+func TestConfigDirList(t *testing.T) {
+	system, local, missing := t.TempDir(), t.TempDir(), path.Join(t.TempDir(), "missing")
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, strings.Join([]string{system, missing, local}, string(os.PathListSeparator)))
+
+	systemPath := path.Join(system, "config.yaml")
+	localPath := path.Join(local, "config.json")
+	require.NoError(t, os.WriteFile(systemPath, []byte("a: system\n"), 0o600))
+	require.NoError(t, os.WriteFile(localPath, []byte(`{"a": "local"}`), 0o600))
+
+	cfg := &testConfig{}
+	res, err := NewWithResult(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "local", cfg.A)
+	assert.Equal(t, []string{localPath}, res.LoadedPaths)
+
+	cfg = &testConfig{}
+	res, err = NewWithResult(cfg, WithMergeAllFound[testConfig]())
+	require.NoError(t, err)
+	assert.Equal(t, "local", cfg.A)
+	assert.Equal(t, []string{systemPath, localPath}, res.LoadedPaths)
+}
+
+func TestConfigFileList(t *testing.T) {
+	type layered struct {
+		A string `json:"a" yaml:"a"`
+		B string `json:"b" yaml:"b"`
+	}
+
+	dir := t.TempDir()
+	basePath := path.Join(dir, "base.yaml")
+	prodPath := path.Join(dir, "prod.json")
+	missingPath := path.Join(dir, "missing.json")
+	require.NoError(t, os.WriteFile(basePath, []byte("a: base\nb: base\n"), 0o600))
+	require.NoError(t, os.WriteFile(prodPath, []byte(`{"b": "prod"}`), 0o600))
+	t.Setenv(FilePathEnvName, strings.Join([]string{basePath, prodPath, missingPath}, string(os.PathListSeparator)))
+
+	t.Run("NoCreate", func(t *testing.T) {
+		cfg := &layered{}
+		res, err := NewWithResult(cfg, WithNoCreate[layered]())
+		require.NoError(t, err)
+		assert.Equal(t, &layered{A: "base", B: "prod"}, cfg)
+		assert.Equal(t, []string{basePath, prodPath}, res.LoadedPaths)
+		assert.Equal(t, []string{missingPath}, res.SkippedMissing)
+		assert.NoFileExists(t, missingPath)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		cfg := &layered{}
+		require.NoError(t, New(cfg))
+		assert.Equal(t, &layered{A: "base", B: "prod"}, cfg)
+		assert.FileExists(t, missingPath)
+	})
+}
+
+// setStdin replaces os.Stdin with a file holding data for the duration of the test.
+func setStdin(t *testing.T, data string) {
+	t.Helper()
+	fpath := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, os.WriteFile(fpath, []byte(data), 0o600))
+	f, err := os.Open(fpath)
+	require.NoError(t, err)
+
+	orig := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = orig
+		_ = f.Close()
+	})
+}
+
+func TestWithStdin(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, t.TempDir())
+		setStdin(t, "a: stdin\n")
+
+		cfg := new(testConfig)
+		res, err := NewWithResult(cfg, WithStdin[testConfig]("yaml"), WithSyncingConfigToFiles[testConfig]())
+		require.NoError(t, err)
+		assert.Equal(t, "stdin", cfg.A)
+		assert.Equal(t, []string{stdinPath}, res.LoadedPaths)
+	})
+	t.Run("positive: dash in file list", func(t *testing.T) {
+		dir := t.TempDir()
+		fpath := filepath.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "file"}`), 0o600))
+		t.Setenv(FilePathEnvName, fpath+string(os.PathListSeparator)+stdinPath)
+		setStdin(t, `a = "stdin"`)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithStdin[testConfig]("toml"), WithSyncingConfigToFiles[testConfig]()))
+		assert.Equal(t, "stdin", cfg.A)
+
+		// only the file is written back
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"a": "stdin"}`, string(data))
+		assert.NoFileExists(t, stdinPath)
+	})
+	t.Run("positive: empty stdin", func(t *testing.T) {
+		t.Setenv(FilePathEnvName, stdinPath)
+		setStdin(t, "")
+
+		cfg := &testConfig{A: "preset"}
+		res, err := NewWithResult(cfg, WithStdin[testConfig]("json"), WithRequireFile[testConfig]())
+		require.NoError(t, err)
+		assert.Equal(t, "preset", cfg.A)
+		assert.Equal(t, []string{stdinPath}, res.SkippedEmpty)
+	})
+	t.Run("negative: format not set", func(t *testing.T) {
+		t.Setenv(FilePathEnvName, stdinPath)
+		setStdin(t, `{"a": "stdin"}`)
+
+		err := New(new(testConfig))
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+		assert.NoFileExists(t, stdinPath)
+	})
+}
+
+func TestEnvSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"a": "first"}`), 0o600))
+
+	cfg := new(testConfig)
+	h, err := Open(cfg)
+	require.NoError(t, err)
+
+	// the environment captured by Open is used by later reloads
+	t.Setenv(DirEnvName, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"a": "second"}`), 0o600))
+	require.NoError(t, h.Reload())
+	assert.Equal(t, "second", cfg.A)
+}
+
+func TestWithEnvNames(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"a": "dir"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "config.json"), []byte(`{"a": "other"}`), 0o600))
+	fpath := filepath.Join(other, "app.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "file"}`), 0o600))
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	require.NoError(t, os.Unsetenv("TEST_APP_CONFIG_FILE"))
+	t.Setenv(DirEnvName, other)
+	t.Setenv("TEST_APP_CONFIG_DIR", "")
+	t.Setenv("TEST_CONFIG_DIR", dir)
+
+	t.Run("positive: first set variable", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithDirEnvNames[testConfig]("TEST_APP_CONFIG_DIR", "TEST_CONFIG_DIR", DirEnvName)))
+		assert.Equal(t, "dir", cfg.A)
+	})
+	t.Run("positive: replaces the default name", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithDirEnvNames[testConfig]("TEST_APP_CONFIG_DIR")))
+		assert.Empty(t, cfg.A)
+	})
+	t.Run("positive: file", func(t *testing.T) {
+		t.Setenv("TEST_APP_CONFIG_FILE", fpath)
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithFileEnvNames[testConfig]("TEST_APP_CONFIG_FILE", FilePathEnvName)))
+		assert.Equal(t, "file", cfg.A)
+	})
+}
+
+func TestWithConfigDirAndFile(t *testing.T) {
+	envDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(envDir, "config.json"), []byte(`{"a": "env"}`), 0o600))
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, envDir)
+
+	t.Run("positive: concurrent instances", func(t *testing.T) {
+		dirs := make([]string, 8)
+		for i := range dirs {
+			dirs[i] = t.TempDir()
+			data := fmt.Sprintf(`{"a": "dir%d"}`, i)
+			require.NoError(t, os.WriteFile(filepath.Join(dirs[i], "config.json"), []byte(data), 0o600))
+		}
+
+		wg := sync.WaitGroup{}
+		for i, dir := range dirs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cfg := new(testConfig)
+				assert.NoError(t, New(cfg, WithConfigDir[testConfig](dir)))
+				assert.Equal(t, fmt.Sprintf("dir%d", i), cfg.A)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, envDir, os.Getenv(DirEnvName))
+	})
+	t.Run("positive: file path takes precedence", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "app.yaml")
+		require.NoError(t, os.WriteFile(fpath, []byte(`a: file`), 0o600))
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithConfigFile[testConfig](fpath), WithConfigDir[testConfig](t.TempDir())))
+		assert.Equal(t, "file", cfg.A)
+	})
+	t.Run("positive: dir takes precedence over env file", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "env file"}`), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithConfigDir[testConfig](envDir)))
+		assert.Equal(t, "env", cfg.A)
+	})
+	t.Run("positive: env is the fallback", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg))
+		assert.Equal(t, "env", cfg.A)
+	})
+}
@@ -0,0 +1,42 @@
+package confix
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowsPaths(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "conf.d")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	require.True(t, strings.Contains(dir, `\`))
+
+	t.Run("Dir", func(t *testing.T) {
+		t.Setenv(FilePathEnvName, "")
+		t.Setenv(DirEnvName, dir)
+		fpath := filepath.Join(dir, "config.JSON")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "windows"}`), 0o600))
+		t.Cleanup(func() { _ = os.Remove(fpath) })
+
+		cfg := &testConfig{}
+		res, err := NewWithResult(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "windows", cfg.A)
+		assert.Equal(t, []string{filepath.Join(dir, "config.json")}, res.LoadedPaths)
+	})
+
+	t.Run("FilePath", func(t *testing.T) {
+		fpath := dir + `\config.yaml`
+		t.Setenv(FilePathEnvName, fpath)
+
+		require.NoError(t, New(&testConfig{A: "created"}))
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, "a: created\n", string(data))
+	})
+}
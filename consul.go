@@ -0,0 +1,76 @@
+package confix
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// consulRetryDelay is the time OnConsulChange waits before retrying a failed blocking query.
+var consulRetryDelay = time.Second
+
+// ConsulClient is the part of a Consul KV client used by WithConsul and OnConsulChange. It is implemented
+// by a small adapter around the caller's *consulapi.Client, so that confix doesn't depend on
+// a particular version of the Consul API client.
+type ConsulClient interface {
+	// Get returns the value stored at key, whether the key exists and the index of the KV store.
+	// With a non-zero waitIndex it is a blocking query: it returns once the index exceeds waitIndex
+	// or the wait time of the client runs out, or with the error of ctx once it is done.
+	Get(ctx context.Context, key string, waitIndex uint64) (value []byte, found bool, index uint64, err error)
+}
+
+// consulSource returns a remoteSource decoding the value stored at key in Consul.
+func consulSource(client ConsulClient, key, format string) remoteSource {
+	return keyValueSource("consul", key, format, func(ctx context.Context) ([]byte, bool, error) {
+		value, found, _, err := client.Get(ctx, key, 0)
+		return value, found, err
+	})
+}
+
+// OnConsulChange starts a goroutine that watches key, usually the key given to WithConsul, with
+// blocking queries and calls Reload every time its index changes. Query and reload errors are reported
+// through the logger; failed queries are retried after a second. The returned stop function cancels
+// the query in flight and ends the goroutine; it is safe to call more than once.
+func OnConsulChange[T any](h *Config[T], client ConsulClient, key string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var index uint64
+		for ctx.Err() == nil {
+			_, _, next, err := client.Get(ctx, key, index)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				h.c.logf("ERROR: watching consul key %q; err=%v", key, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(consulRetryDelay):
+				}
+				continue
+			}
+
+			// the first query only establishes the index; the index may also go backwards,
+			// e.g. after a snapshot restore, which is a change as well
+			if index != 0 && next != index {
+				if err = h.Reload(); err != nil {
+					h.c.logf("ERROR: reloading config on consul change; err=%v", err)
+				}
+			}
+			// an index of 0 would turn the next query into a busy loop
+			index = max(next, 1)
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			cancel()
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package confix
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsul is an in-memory ConsulClient whose index grows with every put.
+type fakeConsul struct {
+	mu      sync.Mutex
+	values  map[string]string
+	index   uint64
+	err     error
+	changed chan struct{}
+}
+
+func newFakeConsul(values map[string]string) *fakeConsul {
+	return &fakeConsul{values: values, index: 1, changed: make(chan struct{})}
+}
+
+func (f *fakeConsul) Get(ctx context.Context, key string, waitIndex uint64) ([]byte, bool, uint64, error) {
+	for {
+		f.mu.Lock()
+		v, ok := f.values[key]
+		index, err, changed := f.index, f.err, f.changed
+		f.mu.Unlock()
+		if err != nil || index > waitIndex {
+			return []byte(v), ok, index, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false, 0, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (f *fakeConsul) put(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func TestWithConsul(t *testing.T) {
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, t.TempDir())
+	client := newFakeConsul(map[string]string{
+		"app/config.toml": `a = "toml"`,
+		"app/config":      `{"a": "json"}`,
+	})
+
+	t.Run("positive: key suffix", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithConsul[testConfig](client, "app/config.toml", "")))
+		assert.Equal(t, "toml", cfg.A)
+	})
+	t.Run("positive: format", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithConsul[testConfig](client, "app/config", ".json")))
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("positive: local files override", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte(`a: local`), 0o600))
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithConsul[testConfig](client, "app/config.toml", "")))
+		assert.Equal(t, "local", cfg.A)
+	})
+	t.Run("negative: missing key", func(t *testing.T) {
+		err := New(new(testConfig), WithConsul[testConfig](client, "app/missing.yaml", ""))
+		assert.ErrorContains(t, err, `consul key "app/missing.yaml": key not found`)
+	})
+	t.Run("negative: client error", func(t *testing.T) {
+		failing := newFakeConsul(nil)
+		failing.err = errors.New("connection refused")
+		err := New(new(testConfig), WithConsul[testConfig](failing, "app/config.toml", ""))
+		assert.ErrorContains(t, err, "connection refused")
+	})
+	t.Run("negative: nil client", func(t *testing.T) {
+		assert.Error(t, New(new(testConfig), WithConsul[testConfig](nil, "app/config.toml", "")))
+	})
+}
+
+func TestOnConsulChange(t *testing.T) {
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, t.TempDir())
+	client := newFakeConsul(map[string]string{"app/config.yaml": "a: before"})
+
+	h, err := Open(new(testConfig), WithConsul[testConfig](client, "app/config.yaml", ""))
+	require.NoError(t, err)
+
+	stop := OnConsulChange(h, client, "app/config.yaml")
+	defer stop()
+
+	// let the watch establish the index before the change
+	time.Sleep(50 * time.Millisecond)
+	client.put("app/config.yaml", "a: after")
+	assert.Eventually(t, func() bool {
+		h.c.mu.RLock()
+		defer h.c.mu.RUnlock()
+		return h.c.cfg.A == "after"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stop()
+	stop()
+}
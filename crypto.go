@@ -0,0 +1,217 @@
+package confix
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// encryptedFileHeader is the first line of configuration files encrypted as a whole.
+// The rest of the file is the base64-encoded nonce followed by the AES-GCM ciphertext.
+const encryptedFileHeader = "CONFIX-AES-GCM\n"
+
+// errCiphertextTooShort is returned for ciphertexts shorter than the nonce.
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+// newAEAD returns AES-GCM with the key, which must be 16, 24 or 32 bytes long.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptValue encrypts plaintext with AES-GCM using the key and returns the base64-encoded
+// nonce and ciphertext, suitable for fields tagged `config:"...,encrypted"`.
+func EncryptValue(key []byte, plaintext string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	return sealValue(aead, []byte(plaintext))
+}
+
+// EncryptFile encrypts the contents of a configuration file with AES-GCM using the key.
+// The result is decrypted as a whole by WithDecryption.
+func EncryptFile(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return encryptFile(aead, data)
+}
+
+// sealValue encrypts plaintext with a random nonce and returns them base64-encoded.
+func sealValue(aead cipher.AEAD, plaintext []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// openValue decodes the base64-encoded nonce and ciphertext produced by sealValue and decrypts it.
+func openValue(aead cipher.AEAD, s string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errCiphertextTooShort
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// encryptFile encrypts data as a whole file prefixed with encryptedFileHeader.
+func encryptFile(aead cipher.AEAD, data []byte) ([]byte, error) {
+	s, err := sealValue(aead, data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encryptedFileHeader + s + "\n"), nil
+}
+
+// decryptFile returns a reader of the decrypted contents of r if it starts with
+// encryptedFileHeader and a reader of the unchanged contents otherwise.
+func decryptFile(aead cipher.AEAD, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(encryptedFileHeader))
+	if err != nil || string(head) != encryptedFileHeader {
+		return br, nil
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openValue(aead, string(data[len(encryptedFileHeader):]))
+	if err != nil {
+		return nil, fmt.Errorf("error while decrypting file: %w", err)
+	}
+	return bytes.NewReader(plaintext), nil
+}
+
+// isEncryptedFile reports whether data starts with encryptedFileHeader.
+func isEncryptedFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedFileHeader))
+}
+
+// cryptFields replaces every non-empty string field tagged with the encrypted modifier,
+// found in v and in nested structs and pointers to structs, with the result of fn
+// called with the dotted name of the field and its value.
+// With copyPointers pointers to structs are replaced by modified copies, so that
+// the values they point to stay untouched.
+func cryptFields(v reflect.Value, prefix string, copyPointers bool, fn func(name, s string) (string, error)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+		key, opts := parseConfigTag(f)
+		if !fv.CanSet() || key == "-" {
+			continue
+		}
+
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if f.Anonymous {
+			name = prefix
+		}
+
+		switch {
+		case fv.Kind() == reflect.String && hasTagOption(opts, "encrypted"):
+			if fv.String() == "" {
+				continue
+			}
+			s, err := fn(name, fv.String())
+			if err != nil {
+				return fmt.Errorf("error while processing encrypted field %s: %w", name, err)
+			}
+			fv.SetString(s)
+		case fv.Kind() == reflect.Struct:
+			if err := cryptFields(fv, name, copyPointers, fn); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			if copyPointers {
+				cp := reflect.New(fv.Elem().Type())
+				cp.Elem().Set(fv.Elem())
+				fv.Set(cp)
+			}
+			if err := cryptFields(fv.Elem(), name, copyPointers, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encryptedValues returns the values of the non-empty fields of cfg tagged with the encrypted modifier by their names.
+func encryptedValues[T any](cfg *T) map[string]string {
+	values := map[string]string{}
+	v := reflect.ValueOf(cfg).Elem()
+	if v.Kind() != reflect.Struct {
+		return values
+	}
+	_ = cryptFields(v, "", false, func(name, s string) (string, error) {
+		values[name] = s
+		return s, nil
+	})
+	return values
+}
+
+// decryptFields decrypts the fields of cfg tagged with the encrypted modifier in place whose values differ
+// from before, the values returned by encryptedValues before cfg was decoded. Values a source didn't set,
+// such as plaintext defaults and values decrypted from earlier sources, are left as they are.
+// The ciphertexts of decrypted fields are stored in sealed by their names when it isn't nil.
+func decryptFields[T any](aead cipher.AEAD, cfg *T, before, sealed map[string]string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return cryptFields(v, "", false, func(name, s string) (string, error) {
+		if prev, ok := before[name]; ok && prev == s {
+			return s, nil
+		}
+		plaintext, err := openValue(aead, s)
+		if err == nil && sealed != nil {
+			sealed[name] = s
+		}
+		return string(plaintext), err
+	})
+}
+
+// encryptedCopy returns a copy of cfg with the fields tagged with the encrypted modifier encrypted.
+// Fields whose ciphertext in sealed still decrypts to their value keep it, so that unchanged values
+// aren't written with a new random nonce. cfg itself is left untouched.
+func encryptedCopy[T any](aead cipher.AEAD, cfg *T, sealed map[string]string) (*T, error) {
+	cp := new(T)
+	*cp = *cfg
+	v := reflect.ValueOf(cp).Elem()
+	if v.Kind() != reflect.Struct {
+		return cp, nil
+	}
+	err := cryptFields(v, "", true, func(name, s string) (string, error) {
+		if prev, ok := sealed[name]; ok {
+			if plaintext, err := openValue(aead, prev); err == nil && string(plaintext) == s {
+				return prev, nil
+			}
+		}
+		return sealValue(aead, []byte(s))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
@@ -0,0 +1,203 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+type encryptedConfig struct {
+	User     string `json:"user"`
+	Password string `json:"password" config:"password,encrypted"`
+	DB       *struct {
+		Token string `json:"token" config:"token,encrypted"`
+	} `json:"db"`
+}
+
+func TestWithDecryption(t *testing.T) {
+	t.Run("Fields", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+
+		password, err := EncryptValue(testKey, "pa55")
+		require.NoError(t, err)
+		token, err := EncryptValue(testKey, "t0ken")
+		require.NoError(t, err)
+		fpath := path.Join(dir, "config.json")
+		data := `{"user": "admin", "password": "` + password + `", "db": {"token": "` + token + `"}}`
+		require.NoError(t, os.WriteFile(fpath, []byte(data), 0o600))
+
+		cfg := &encryptedConfig{}
+		require.NoError(t, New(cfg, WithDecryption[encryptedConfig](testKey), WithSyncingConfigToFiles[encryptedConfig]()))
+		assert.Equal(t, "admin", cfg.User)
+		assert.Equal(t, "pa55", cfg.Password)
+		assert.Equal(t, "t0ken", cfg.DB.Token)
+
+		written, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.NotContains(t, string(written), "pa55")
+		assert.NotContains(t, string(written), "t0ken")
+
+		again := &encryptedConfig{}
+		require.NoError(t, New(again, WithDecryption[encryptedConfig](testKey)))
+		assert.Equal(t, cfg, again)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+
+		data, err := EncryptFile(testKey, []byte("user: admin\n"))
+		require.NoError(t, err)
+		fpath := path.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(fpath, data, 0o600))
+
+		cfg := &encryptedConfig{}
+		require.NoError(t, New(cfg,
+			WithDecryption[encryptedConfig](testKey),
+			WithValidation(func(cfg *encryptedConfig) error {
+				cfg.User = "root"
+				return nil
+			}),
+			WithSyncingConfigToFiles[encryptedConfig](),
+		))
+
+		written, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(written), encryptedFileHeader))
+		assert.NotContains(t, string(written), "root")
+
+		again := &encryptedConfig{}
+		require.NoError(t, New(again, WithDecryption[encryptedConfig](testKey)))
+		assert.Equal(t, "root", again.User)
+	})
+
+	t.Run("PlaintextDefault", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"user": "admin"}`), 0o600))
+
+		type defaultConfig struct {
+			User     string `json:"user"`
+			Password string `json:"password" config:"password,encrypted" default:"changeme"`
+		}
+		cfg := &defaultConfig{}
+		require.NoError(t, New(cfg, WithDecryption[defaultConfig](testKey)))
+		assert.Equal(t, "admin", cfg.User)
+		assert.Equal(t, "changeme", cfg.Password)
+	})
+
+	t.Run("ReloadWithoutKey", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		password, err := EncryptValue(testKey, "pa55")
+		require.NoError(t, err)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"user": "admin", "password": "`+password+`"}`), 0o600))
+
+		h, err := Open(&encryptedConfig{}, WithDecryption[encryptedConfig](testKey))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"user": "root"}`), 0o600))
+		require.NoError(t, h.Reload())
+		require.NoError(t, h.Reload())
+		assert.Equal(t, "root", h.c.cfg.User)
+		assert.Equal(t, "pa55", h.c.cfg.Password)
+	})
+
+	t.Run("SkipUnchangedFields", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		password, err := EncryptValue(testKey, "pa55")
+		require.NoError(t, err)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"user": "admin", "password": "`+password+`"}`), 0o600))
+
+		opts := []Option[encryptedConfig]{
+			WithDecryption[encryptedConfig](testKey),
+			WithSkipUnchanged[encryptedConfig](),
+			WithSyncingConfigToFiles[encryptedConfig](),
+		}
+		require.NoError(t, New(&encryptedConfig{}, opts...))
+		written, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Contains(t, string(written), password)
+
+		require.NoError(t, New(&encryptedConfig{}, opts...))
+		again, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, string(written), string(again))
+	})
+
+	t.Run("SkipUnchangedFile", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		data, err := EncryptFile(testKey, []byte("user: admin\n"))
+		require.NoError(t, err)
+		fpath := path.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(fpath, data, 0o600))
+
+		opts := []Option[encryptedConfig]{
+			WithDecryption[encryptedConfig](testKey),
+			WithSkipUnchanged[encryptedConfig](),
+			WithSyncingConfigToFiles[encryptedConfig](),
+		}
+		require.NoError(t, New(&encryptedConfig{}, opts...))
+		written, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+
+		require.NoError(t, New(&encryptedConfig{}, opts...))
+		again, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, string(written), string(again))
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+
+		password, err := EncryptValue(testKey, "pa55")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"password": "`+password+`"}`), 0o600))
+
+		err = New(&encryptedConfig{}, WithDecryption[encryptedConfig]([]byte("fedcba9876543210fedcba9876543210")))
+		assert.ErrorContains(t, err, "password")
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		err := New(&encryptedConfig{}, WithDecryption[encryptedConfig]([]byte("short")))
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptedCopy(t *testing.T) {
+	aead, err := newAEAD(testKey)
+	require.NoError(t, err)
+
+	cfg := &encryptedConfig{Password: "pa55"}
+	cfg.DB = &struct {
+		Token string `json:"token" config:"token,encrypted"`
+	}{Token: "t0ken"}
+
+	cp, err := encryptedCopy(aead, cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pa55", cfg.Password)
+	assert.Equal(t, "t0ken", cfg.DB.Token)
+	assert.NotEqual(t, "pa55", cp.Password)
+	assert.NotEqual(t, "t0ken", cp.DB.Token)
+
+	require.NoError(t, decryptFields(aead, cp, nil, nil))
+	assert.Equal(t, cfg, cp)
+}
@@ -0,0 +1,43 @@
+package confix
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// defaultTagName is the struct tag holding the default value of a configuration field.
+const defaultTagName = "default"
+
+// applyDefaults walks the struct v and assigns the value of the default tag to every field
+// that still holds its zero value. Nested structs are processed recursively, except for structs
+// with codecs or implementing encoding.TextUnmarshaler, such as time.Time, which take the tag as a value.
+func applyDefaults(v reflect.Value) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && !isTextType(fv.Type()) {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		def, ok := f.Tag.Lookup(defaultTagName)
+		if !ok || !fv.IsZero() {
+			continue
+		}
+		if err := setFieldFromString(fv, def); err != nil {
+			return fmt.Errorf("error while parsing default value of field %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type defaultsTestConfig struct {
+	Host    string        `config:"host" json:"host" default:"localhost"`
+	Port    int           `config:"port" json:"port" default:"8080"`
+	Ratio   float64       `config:"ratio" json:"ratio" default:"0.5"`
+	Debug   bool          `config:"debug" json:"debug" default:"true"`
+	Timeout time.Duration `config:"timeout" json:"timeout" default:"2s"`
+	Tags    []string      `config:"tags" json:"tags" default:"a, b"`
+	Ports   []uint16      `config:"ports" json:"ports" default:"80,443"`
+	NoTag   string        `config:"no_tag" json:"no_tag"`
+	Since   time.Time     `config:"since" json:"since" default:"2024-01-02T00:00:00Z"`
+	Nested  struct {
+		Name string `config:"name" json:"name" default:"nested"`
+	} `config:"nested" json:"nested"`
+}
+
+func TestApplyDefaults(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		cfg := new(defaultsTestConfig)
+		require.NoError(t, applyDefaults(reflect.ValueOf(cfg).Elem()))
+		assert.Equal(t, "localhost", cfg.Host)
+		assert.Equal(t, 8080, cfg.Port)
+		assert.Equal(t, 0.5, cfg.Ratio)
+		assert.True(t, cfg.Debug)
+		assert.Equal(t, 2*time.Second, cfg.Timeout)
+		assert.Equal(t, []string{"a", "b"}, cfg.Tags)
+		assert.Equal(t, []uint16{80, 443}, cfg.Ports)
+		assert.Empty(t, cfg.NoTag)
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), cfg.Since)
+		assert.Equal(t, "nested", cfg.Nested.Name)
+	})
+	t.Run("positive: preset values are kept", func(t *testing.T) {
+		cfg := &defaultsTestConfig{Host: "example.com", Port: 1}
+		require.NoError(t, applyDefaults(reflect.ValueOf(cfg).Elem()))
+		assert.Equal(t, "example.com", cfg.Host)
+		assert.Equal(t, 1, cfg.Port)
+	})
+	t.Run("negative: invalid default", func(t *testing.T) {
+		type invalid struct {
+			Port int `default:"port"`
+		}
+		err := applyDefaults(reflect.ValueOf(new(invalid)).Elem())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "Port")
+		}
+	})
+}
+
+func TestNew_Defaults(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"host": "file"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	cfg := new(defaultsTestConfig)
+	require.NoError(t, New(cfg))
+	assert.Equal(t, "file", cfg.Host)
+	assert.Equal(t, 8080, cfg.Port)
+}
+
+func TestDefaults(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"host": "file"}`), 0o600))
+
+	t.Run("positive", func(t *testing.T) {
+		cfg, err := Defaults[defaultsTestConfig]()
+		require.NoError(t, err)
+		assert.Equal(t, "localhost", cfg.Host)
+		assert.Equal(t, 8080, cfg.Port)
+		assert.Equal(t, "nested", cfg.Nested.Name)
+	})
+	t.Run("positive: env overrides", func(t *testing.T) {
+		t.Setenv("APP_PORT", "9090")
+		cfg, err := Defaults(WithEnvOverrides[defaultsTestConfig]("APP"))
+		require.NoError(t, err)
+		assert.Equal(t, "localhost", cfg.Host)
+		assert.Equal(t, 9090, cfg.Port)
+	})
+	t.Run("positive: required fields are not checked", func(t *testing.T) {
+		cfg, err := Defaults[requiredTestConfig]()
+		require.NoError(t, err)
+		assert.Equal(t, &requiredTestConfig{}, cfg)
+	})
+	t.Run("negative: invalid default", func(t *testing.T) {
+		type invalidConfig struct {
+			Port int `default:"http"`
+		}
+		cfg, err := Defaults[invalidConfig]()
+		assert.Nil(t, cfg)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,55 @@
+package confix
+
+import (
+	"reflect"
+)
+
+// deprecatedTagName is the struct tag holding the message logged when a deprecated field is set.
+const deprecatedTagName = "deprecated"
+
+// warnDeprecated walks the struct v and logs the message of the deprecated tag of every field
+// that holds a non-zero value, e.g. `deprecated:"use new_field instead"`. Values equal to the ones
+// set by default tags don't count, as they weren't set by the user. Field names are config keys joined with dots.
+func warnDeprecated(v reflect.Value, prefix string, logf func(format string, args ...any)) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, _ := parseConfigTag(f)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fv := v.Field(i)
+		if msg, ok := f.Tag.Lookup(deprecatedTagName); ok && !fv.IsZero() && !holdsDefault(fv, f) {
+			logf("WARNING: config key %s is deprecated: %s", key, msg)
+			continue
+		}
+		if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			warnDeprecated(fv, key, logf)
+		}
+	}
+}
+
+// holdsDefault reports whether fv, the value of the field f, equals the value applyDefaults sets:
+// the value of the default tag of f or, for structs, the defaults of their fields.
+func holdsDefault(fv reflect.Value, f reflect.StructField) bool {
+	def := reflect.New(fv.Type()).Elem()
+	if tag, ok := f.Tag.Lookup(defaultTagName); ok {
+		if err := setFieldFromString(def, tag); err != nil {
+			return false
+		}
+	} else if err := applyDefaults(def); err != nil || def.IsZero() {
+		return false
+	}
+	return reflect.DeepEqual(def.Interface(), fv.Interface())
+}
@@ -0,0 +1,81 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deprecatedTestConfig struct {
+	Addr    string `config:"addr" json:"addr"`
+	Host    string `config:"host" json:"host" deprecated:"use addr instead"`
+	Timeout int    `config:"timeout" json:"timeout" deprecated:"use db.timeout instead"`
+	DB      *struct {
+		Pool int `config:"pool" json:"pool" deprecated:"pooling is automatic now"`
+	} `config:"db" json:"db"`
+}
+
+func TestWarnDeprecated(t *testing.T) {
+	cfg := &deprecatedTestConfig{Host: "localhost"}
+	logger := &testLogger{}
+	warnDeprecated(reflect.ValueOf(cfg).Elem(), "", logger.Logf)
+	assert.Equal(t, []string{"WARNING: config key host is deprecated: use addr instead"}, logger.messages)
+
+	cfg.DB = &struct {
+		Pool int `config:"pool" json:"pool" deprecated:"pooling is automatic now"`
+	}{Pool: 2}
+	logger = &testLogger{}
+	warnDeprecated(reflect.ValueOf(cfg).Elem(), "", logger.Logf)
+	assert.Equal(t, []string{
+		"WARNING: config key host is deprecated: use addr instead",
+		"WARNING: config key db.pool is deprecated: pooling is automatic now",
+	}, logger.messages)
+}
+
+func TestNew_Deprecated(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"addr": "localhost:80"}`), 0o600))
+	logger := &testLogger{}
+	require.NoError(t, New(new(deprecatedTestConfig), WithLogger[deprecatedTestConfig](logger)))
+	assert.Empty(t, logger.messages)
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"addr": "localhost:80", "timeout": 5}`), 0o600))
+	cfg := new(deprecatedTestConfig)
+	require.NoError(t, New(cfg, WithLogger[deprecatedTestConfig](logger)))
+	assert.Equal(t, 5, cfg.Timeout)
+	assert.Equal(t, []string{"WARNING: config key timeout is deprecated: use db.timeout instead"}, logger.messages)
+}
+
+func TestNew_DeprecatedDefault(t *testing.T) {
+	type defaultConfig struct {
+		Addr  string `config:"addr" json:"addr"`
+		Retry int    `config:"retry" json:"retry" default:"3" deprecated:"use retries instead"`
+		Old   struct {
+			Name string `config:"name" json:"name" default:"old"`
+		} `config:"old" json:"old" deprecated:"use new instead"`
+	}
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"addr": "localhost:80"}`), 0o600))
+	logger := &testLogger{}
+	cfg := new(defaultConfig)
+	require.NoError(t, New(cfg, WithLogger[defaultConfig](logger)))
+	assert.Equal(t, 3, cfg.Retry)
+	assert.Empty(t, logger.messages)
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"retry": 5, "old": {"name": "set"}}`), 0o600))
+	require.NoError(t, New(new(defaultConfig), WithLogger[defaultConfig](logger)))
+	assert.Equal(t, []string{
+		"WARNING: config key retry is deprecated: use retries instead",
+		"WARNING: config key old is deprecated: use new instead",
+	}, logger.messages)
+}
@@ -0,0 +1,64 @@
+package confix
+
+import (
+	"reflect"
+)
+
+// FieldChange describes a field whose value differs between two configurations.
+type FieldChange struct {
+	// Path is the config keys of the field and its parents joined with dots, e.g. "db.host"
+	Path string
+	// Old is the value of the field in the old configuration
+	Old any
+	// New is the value of the field in the new configuration
+	New any
+}
+
+// Diff compares the exported fields of old and new and returns the changed ones in declaration order.
+// Nested structs and pointers to structs are compared field by field, other values, including
+// slices, maps and structs with unexported fields such as time.Time, are compared as a whole.
+func Diff[T any](old, new *T) []FieldChange {
+	var changes []FieldChange
+	diffValues(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	return changes
+}
+
+// diffValues appends the differences between a and b, which have the same type, to changes.
+func diffValues(a, b reflect.Value, path string, changes *[]FieldChange) {
+	switch {
+	case a.Kind() == reflect.Struct && !hasUnexportedFields(a.Type()):
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key, _ := parseConfigTag(f)
+			if key == "-" || !f.IsExported() && f.Type.Kind() != reflect.Struct {
+				continue
+			}
+			switch {
+			case f.Anonymous:
+				key = path
+			case path != "":
+				key = path + "." + key
+			}
+			diffValues(a.Field(i), b.Field(i), key, changes)
+		}
+		return
+	case a.Kind() == reflect.Pointer && a.Type().Elem().Kind() == reflect.Struct && !a.IsNil() && !b.IsNil():
+		diffValues(a.Elem(), b.Elem(), path, changes)
+		return
+	}
+
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*changes = append(*changes, FieldChange{Path: path, Old: a.Interface(), New: b.Interface()})
+	}
+}
+
+// hasUnexportedFields reports whether the struct type t has unexported fields other than embedded structs.
+func hasUnexportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); !f.IsExported() && !(f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package confix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	type db struct {
+		Host string `config:"host"`
+		Port int    `config:"port"`
+	}
+	type embedded struct {
+		Region string `config:"region"`
+	}
+	type diffConfig struct {
+		embedded
+		Name    string            `config:"name"`
+		DB      db                `config:"db"`
+		Replica *db               `config:"replica"`
+		Backup  *db               `config:"backup"`
+		Tags    []string          `config:"tags"`
+		Labels  map[string]string `config:"labels"`
+		Started time.Time         `config:"started"`
+		Ignored string            `config:"-"`
+	}
+
+	now := time.Now()
+	old := &diffConfig{
+		embedded: embedded{Region: "eu"},
+		Name:     "app",
+		DB:       db{Host: "localhost", Port: 5432},
+		Replica:  &db{Host: "replica", Port: 5432},
+		Tags:     []string{"a"},
+		Labels:   map[string]string{"env": "dev"},
+		Started:  now,
+		Ignored:  "old",
+	}
+	assert.Empty(t, Diff(old, old))
+
+	updated := &diffConfig{
+		embedded: embedded{Region: "us"},
+		Name:     "app",
+		DB:       db{Host: "db", Port: 5432},
+		Replica:  &db{Host: "replica", Port: 5433},
+		Backup:   &db{Host: "backup"},
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"env": "prod"},
+		Started:  now.Add(time.Second),
+		Ignored:  "new",
+	}
+	assert.Equal(t, []FieldChange{
+		{Path: "region", Old: "eu", New: "us"},
+		{Path: "db.host", Old: "localhost", New: "db"},
+		{Path: "replica.port", Old: 5432, New: 5433},
+		{Path: "backup", Old: (*db)(nil), New: &db{Host: "backup"}},
+		{Path: "tags", Old: []string{"a"}, New: []string{"a", "b"}},
+		{Path: "labels", Old: map[string]string{"env": "dev"}, New: map[string]string{"env": "prod"}},
+		{Path: "started", Old: now, New: now.Add(time.Second)},
+	}, Diff(old, updated))
+}
@@ -0,0 +1,135 @@
+package confix
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// applyEnvOverrides walks the struct v and assigns values of the matching environment variables
+// to its fields. Variable names are built from the prefix and the uppercased config tag of the field,
+// joined with underscores. Nested structs and pointers to structs extend the prefix with their own key;
+// nil pointers are allocated only when a variable with that prefix is set. Structs with codecs or
+// implementing encoding.TextUnmarshaler, such as time.Time, are parsed from a single variable.
+// Slices are replaced by a comma-separated variable and then overridden element by element
+// by indexed variables, see applyIndexedEnv.
+func applyEnvOverrides(v reflect.Value, prefix string) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, _ := parseConfigTag(f)
+		if key == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if f.Anonymous && fv.Kind() == reflect.Struct {
+			if err := applyEnvOverrides(fv, prefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := envName(prefix, key)
+		if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct && !isTextType(fv.Type().Elem()) {
+			if fv.IsNil() {
+				if !hasEnvPrefix(name + "_") {
+					continue
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && !isTextType(fv.Type()) {
+			if err := applyEnvOverrides(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if val, ok := os.LookupEnv(name); ok {
+			if err := setFieldFromString(fv, val); err != nil {
+				return fmt.Errorf("error while parsing env %s: %w", name, err)
+			}
+		}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			if err := applyIndexedEnv(fv, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyIndexedEnv overrides the elements of the slice or array v with the variables named after name
+// and the index, e.g. APP_HOSTS_0, after the comma-separated value of name has been applied.
+// Slices are extended while the variable of the next index is set. Struct elements take their fields
+// from variables like APP_SERVERS_0_HOST.
+func applyIndexedEnv(v reflect.Value, name string) error {
+	isStruct := v.Type().Elem().Kind() == reflect.Struct
+	for i := 0; ; i++ {
+		elemName := fmt.Sprintf("%s_%d", name, i)
+		val, ok := os.LookupEnv(elemName)
+		if isStruct {
+			ok = hasEnvPrefix(elemName + "_")
+		}
+		if i >= v.Len() {
+			if !ok || v.Kind() == reflect.Array {
+				return nil
+			}
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+		if !ok {
+			continue
+		}
+
+		if isStruct {
+			if err := applyEnvOverrides(v.Index(i), elemName); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setFieldFromString(v.Index(i), val); err != nil {
+			return fmt.Errorf("error while parsing env %s: %w", elemName, err)
+		}
+	}
+}
+
+// hasEnvPrefix reports whether a variable with a name starting with prefix is set.
+func hasEnvPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// envName joins the prefix and the uppercased key with an underscore.
+func envName(prefix, key string) string {
+	key = strings.ToUpper(key)
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+// expandEnv replaces ${VAR} and $VAR references in s with values of environment variables.
+// The ${VAR:-default} form yields default when the variable is unset or empty.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		name, def, hasDefault := strings.Cut(name, ":-")
+		if val := os.Getenv(name); val != "" || !hasDefault {
+			return val
+		}
+		return def
+	})
+}
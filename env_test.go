@@ -0,0 +1,217 @@
+package confix
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type envTestConfig struct {
+	A        string        `config:"a"`
+	B        int           `config:"b"`
+	C        bool          `config:"c"`
+	D        float64       `config:"d"`
+	Timeout  time.Duration `config:"timeout"`
+	Untagged uint16
+	Skipped  string `config:"-"`
+	Nested   struct {
+		E string `config:"e"`
+	} `config:"nested"`
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		t.Setenv("APP_A", "a")
+		t.Setenv("APP_B", "42")
+		t.Setenv("APP_C", "true")
+		t.Setenv("APP_D", "1.5")
+		t.Setenv("APP_TIMEOUT", "3s")
+		t.Setenv("APP_UNTAGGED", "7")
+		t.Setenv("APP_-", "skipped")
+		t.Setenv("APP_NESTED_E", "e")
+
+		cfg := &envTestConfig{Skipped: "kept"}
+		c := &config[envTestConfig]{cfg: cfg}
+		require.NoError(t, WithEnvOverrides[envTestConfig]("APP").apply(c))
+
+		assert.Equal(t, "a", cfg.A)
+		assert.Equal(t, 42, cfg.B)
+		assert.True(t, cfg.C)
+		assert.Equal(t, 1.5, cfg.D)
+		assert.Equal(t, 3*time.Second, cfg.Timeout)
+		assert.Equal(t, uint16(7), cfg.Untagged)
+		assert.Equal(t, "kept", cfg.Skipped)
+		assert.Equal(t, "e", cfg.Nested.E)
+	})
+	t.Run("positive: unset keeps value", func(t *testing.T) {
+		cfg := &envTestConfig{A: "file"}
+		require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "UNSET_PREFIX"))
+		assert.Equal(t, "file", cfg.A)
+	})
+	t.Run("positive: text and pointer structs", func(t *testing.T) {
+		type server struct {
+			Host string `config:"host"`
+		}
+		type textConfig struct {
+			T       time.Time `config:"t"`
+			P       *server   `config:"p"`
+			Set     *server   `config:"set"`
+			Missing *server   `config:"missing"`
+		}
+		t.Setenv("APP_T", "2024-01-02T00:00:00Z")
+		t.Setenv("APP_P_HOST", "db")
+		t.Setenv("APP_SET_HOST", "cache")
+
+		cfg := &textConfig{Set: &server{Host: "file"}}
+		require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "APP"))
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), cfg.T)
+		assert.Equal(t, &server{Host: "db"}, cfg.P)
+		assert.Equal(t, &server{Host: "cache"}, cfg.Set)
+		assert.Nil(t, cfg.Missing)
+	})
+	t.Run("negative: parse error", func(t *testing.T) {
+		t.Setenv("APP_B", "not a number")
+		c := &config[envTestConfig]{cfg: new(envTestConfig)}
+		err := WithEnvOverrides[envTestConfig]("APP").apply(c)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "APP_B")
+		}
+	})
+}
+
+func TestApplyEnvOverridesSlices(t *testing.T) {
+	type server struct {
+		Host string `config:"host"`
+		Port int    `config:"port"`
+	}
+	type sliceConfig struct {
+		Hosts   []string `config:"hosts"`
+		Ports   []int    `config:"ports"`
+		Servers []server `config:"servers"`
+		Pair    [2]string
+	}
+
+	t.Run("positive: comma-separated", func(t *testing.T) {
+		t.Setenv("APP_HOSTS", "a, b,c")
+		cfg := &sliceConfig{Hosts: []string{"file"}}
+		require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "APP"))
+		assert.Equal(t, []string{"a", "b", "c"}, cfg.Hosts)
+	})
+	t.Run("positive: indexed", func(t *testing.T) {
+		t.Setenv("APP_HOSTS_0", "a,1")
+		t.Setenv("APP_HOSTS_1", "b")
+		t.Setenv("APP_HOSTS_3", "ignored after a gap")
+		t.Setenv("APP_PORTS_1", "8081")
+		t.Setenv("APP_PAIR_1", "second")
+		t.Setenv("APP_PAIR_2", "out of range")
+		cfg := &sliceConfig{Ports: []int{80, 81, 82}, Pair: [2]string{"first"}}
+		require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "APP"))
+		assert.Equal(t, []string{"a,1", "b"}, cfg.Hosts)
+		assert.Equal(t, []int{80, 8081, 82}, cfg.Ports)
+		assert.Equal(t, [2]string{"first", "second"}, cfg.Pair)
+	})
+	t.Run("positive: indexed after comma-separated", func(t *testing.T) {
+		t.Setenv("APP_HOSTS", "a,b")
+		t.Setenv("APP_HOSTS_1", "x")
+		t.Setenv("APP_HOSTS_2", "y")
+		cfg := new(sliceConfig)
+		require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "APP"))
+		assert.Equal(t, []string{"a", "x", "y"}, cfg.Hosts)
+	})
+	t.Run("positive: structs", func(t *testing.T) {
+		t.Setenv("APP_SERVERS_0_PORT", "8080")
+		t.Setenv("APP_SERVERS_1_HOST", "b")
+		cfg := &sliceConfig{Servers: []server{{Host: "a", Port: 80}}}
+		require.NoError(t, applyEnvOverrides(reflect.ValueOf(cfg).Elem(), "APP"))
+		assert.Equal(t, []server{{Host: "a", Port: 8080}, {Host: "b"}}, cfg.Servers)
+	})
+	t.Run("negative: parse error", func(t *testing.T) {
+		t.Setenv("APP_PORTS_0", "not a number")
+		err := applyEnvOverrides(reflect.ValueOf(new(sliceConfig)).Elem(), "APP")
+		assert.ErrorContains(t, err, "APP_PORTS_0")
+	})
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("CONFIX_HOST", "db")
+	t.Setenv("CONFIX_EMPTY", "")
+
+	assert.Equal(t, "postgres://db:5432/app", expandEnv("postgres://${CONFIX_HOST}:5432/app"))
+	assert.Equal(t, "db/app", expandEnv("$CONFIX_HOST/app"))
+	assert.Equal(t, "x", expandEnv("x${CONFIX_UNSET}"))
+	assert.Equal(t, "fallback", expandEnv("${CONFIX_UNSET:-fallback}"))
+	assert.Equal(t, "fallback", expandEnv("${CONFIX_EMPTY:-fallback}"))
+	assert.Equal(t, "db", expandEnv("${CONFIX_HOST:-fallback}"))
+}
+
+func TestWithEnvExpansion(t *testing.T) {
+	t.Setenv("CONFIX_HOST", "db")
+
+	type nested struct {
+		DSN string
+	}
+	type expansionConfig struct {
+		DSN    string
+		Hosts  []string
+		Labels map[string]string
+		Nested nested
+		Ptr    *nested
+		Port   int
+	}
+	cfg := &expansionConfig{
+		DSN:    "postgres://${CONFIX_HOST}/app",
+		Hosts:  []string{"$CONFIX_HOST", "${CONFIX_UNSET:-other}"},
+		Labels: map[string]string{"host": "${CONFIX_HOST}"},
+		Nested: nested{DSN: "${CONFIX_HOST}"},
+		Ptr:    &nested{DSN: "${CONFIX_HOST}"},
+		Port:   1,
+	}
+	c := &config[expansionConfig]{cfg: cfg}
+	require.NoError(t, WithEnvExpansion[expansionConfig]().apply(c))
+
+	assert.Equal(t, &expansionConfig{
+		DSN:    "postgres://db/app",
+		Hosts:  []string{"db", "other"},
+		Labels: map[string]string{"host": "db"},
+		Nested: nested{DSN: "db"},
+		Ptr:    &nested{DSN: "db"},
+		Port:   1,
+	}, cfg)
+}
+
+func TestWithEnvExpansionFormats(t *testing.T) {
+	// Values containing quotes would break the file syntax if references were expanded in raw bytes.
+	t.Setenv("CONFIX_HOST", `db"primary`)
+
+	type expansionConfig struct {
+		DSN   string   `json:"dsn" yaml:"dsn" toml:"dsn" config:"dsn"`
+		Hosts []string `json:"hosts" yaml:"hosts" toml:"hosts" config:"hosts"`
+	}
+	files := map[string]string{
+		"config.json": `{"dsn": "postgres://${CONFIX_HOST}/app", "hosts": ["$CONFIX_HOST", "${CONFIX_UNSET:-other}"]}`,
+		"config.yaml": "dsn: postgres://${CONFIX_HOST}/app\nhosts: [\"$CONFIX_HOST\", \"${CONFIX_UNSET:-other}\"]\n",
+		"config.toml": "dsn = \"postgres://${CONFIX_HOST}/app\"\nhosts = [\"$CONFIX_HOST\", \"${CONFIX_UNSET:-other}\"]\n",
+		"config.ini":  "dsn = postgres://${CONFIX_HOST}/app\nhosts = $CONFIX_HOST,${CONFIX_UNSET:-other}\n",
+	}
+
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.Unsetenv(FilePathEnvName))
+			t.Setenv(DirEnvName, dir)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+
+			cfg := &expansionConfig{}
+			require.NoError(t, New(cfg, WithEnvExpansion[expansionConfig]()))
+			assert.Equal(t, &expansionConfig{
+				DSN:   `postgres://db"primary/app`,
+				Hosts: []string{`db"primary`, "other"},
+			}, cfg)
+		})
+	}
+}
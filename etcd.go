@@ -0,0 +1,59 @@
+package confix
+
+import (
+	"context"
+	"sync"
+)
+
+// EtcdClient is the part of an etcd client used by WithEtcd and OnEtcdChange. It is implemented
+// by a small adapter around the caller's *clientv3.Client, so that confix doesn't depend on
+// a particular version of the etcd client.
+type EtcdClient interface {
+	// Get returns the value stored at key and whether the key exists.
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	// Watch returns a channel receiving a value every time key is changed or deleted.
+	// The channel is closed once ctx is done.
+	Watch(ctx context.Context, key string) <-chan struct{}
+}
+
+// etcdSource returns a remoteSource decoding the value stored at key in etcd.
+func etcdSource(client EtcdClient, key, format string) remoteSource {
+	return keyValueSource("etcd", key, format, func(ctx context.Context) ([]byte, bool, error) {
+		return client.Get(ctx, key)
+	})
+}
+
+// OnEtcdChange starts a goroutine that calls Reload every time key, usually the key given to WithEtcd,
+// is changed in etcd. Reload errors are reported through the logger. The returned stop function
+// cancels the watch and ends the goroutine; it is safe to call more than once.
+func OnEtcdChange[T any](h *Config[T], client EtcdClient, key string) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := client.Watch(ctx, key)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-changes:
+				if !ok {
+					return
+				}
+				if err := h.Reload(); err != nil {
+					h.c.logf("ERROR: reloading config on etcd change; err=%v", err)
+				}
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			cancel()
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package confix
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEtcd is an in-memory EtcdClient.
+type fakeEtcd struct {
+	mu       sync.Mutex
+	values   map[string]string
+	err      error
+	watchers map[string][]chan struct{}
+}
+
+func newFakeEtcd(values map[string]string) *fakeEtcd {
+	return &fakeEtcd{values: values, watchers: map[string][]chan struct{}{}}
+}
+
+func (f *fakeEtcd) Get(_ context.Context, key string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[key]
+	return []byte(v), ok, f.err
+}
+
+func (f *fakeEtcd) Watch(ctx context.Context, key string) <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	f.watchers[key] = append(f.watchers[key], ch)
+	return ch
+}
+
+func (f *fakeEtcd) put(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+	for _, ch := range f.watchers[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func TestWithEtcd(t *testing.T) {
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, t.TempDir())
+	client := newFakeEtcd(map[string]string{
+		"app/config.yaml": "a: yaml",
+		"app/config":      `{"a": "json"}`,
+	})
+
+	t.Run("positive: key suffix", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithEtcd[testConfig](client, "app/config.yaml", "")))
+		assert.Equal(t, "yaml", cfg.A)
+	})
+	t.Run("positive: format", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithEtcd[testConfig](client, "app/config", "json")))
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("positive: local files override", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte(`a: local`), 0o600))
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithEtcd[testConfig](client, "app/config.yaml", "")))
+		assert.Equal(t, "local", cfg.A)
+	})
+	t.Run("positive: decoding options", func(t *testing.T) {
+		type poolConfig struct {
+			MaxConns int
+		}
+		policy := newFakeEtcd(map[string]string{"app/pool.json": `{"max_conns": 10, /* comment */}`})
+		cfg := new(poolConfig)
+		require.NoError(t, New(cfg,
+			WithEtcd[poolConfig](policy, "app/pool.json", ""),
+			WithKeyNamingPolicy[poolConfig](snakeCase),
+			WithJSONComments[poolConfig](),
+			WithStrictDecoding[poolConfig](),
+		))
+		assert.Equal(t, 10, cfg.MaxConns)
+	})
+	t.Run("negative: strict decoding", func(t *testing.T) {
+		strict := newFakeEtcd(map[string]string{"app/config.json": `{"a": "json", "unknown": 1}`})
+		err := New(new(testConfig), WithEtcd[testConfig](strict, "app/config.json", ""), WithStrictDecoding[testConfig]())
+		assert.ErrorContains(t, err, "unknown")
+	})
+	t.Run("negative: missing key", func(t *testing.T) {
+		err := New(new(testConfig), WithEtcd[testConfig](client, "app/missing.yaml", ""))
+		assert.ErrorContains(t, err, `etcd key "app/missing.yaml": key not found`)
+	})
+	t.Run("negative: client error", func(t *testing.T) {
+		failing := newFakeEtcd(nil)
+		failing.err = errors.New("connection refused")
+		err := New(new(testConfig), WithEtcd[testConfig](failing, "app/config.yaml", ""))
+		assert.ErrorContains(t, err, "connection refused")
+	})
+	t.Run("negative: unknown format", func(t *testing.T) {
+		err := New(new(testConfig), WithEtcd[testConfig](client, "app/config", ""))
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	})
+	t.Run("negative: nil client", func(t *testing.T) {
+		assert.Error(t, New(new(testConfig), WithEtcd[testConfig](nil, "app/config.yaml", "")))
+	})
+}
+
+func TestOnEtcdChange(t *testing.T) {
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, t.TempDir())
+	client := newFakeEtcd(map[string]string{"app/config.yaml": "a: before"})
+
+	h, err := Open(new(testConfig), WithEtcd[testConfig](client, "app/config.yaml", ""))
+	require.NoError(t, err)
+
+	stop := OnEtcdChange(h, client, "app/config.yaml")
+	defer stop()
+
+	client.put("app/config.yaml", "a: after")
+	assert.Eventually(t, func() bool {
+		h.c.mu.RLock()
+		defer h.c.mu.RUnlock()
+		return h.c.cfg.A == "after"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stop()
+	stop()
+}
@@ -0,0 +1,38 @@
+package confix
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Fingerprint returns the SHA-256 hex digest of the canonical JSON encoding of cfg,
+// in which the keys of all objects are sorted. Equal configurations have equal fingerprints,
+// so comparing them across reloads tells whether the configuration changed.
+func Fingerprint[T any](cfg *T) (string, error) {
+	data, err := canonicalJSON(cfg)
+	if err != nil {
+		return "", fmt.Errorf("error while encoding config for fingerprint: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON returns the JSON encoding of v with the keys of all objects sorted.
+// Numbers are kept as they were encoded to avoid losing precision.
+func canonicalJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err = dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
@@ -0,0 +1,36 @@
+package confix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	type fingerprintConfig struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+		Big    uint64            `json:"big"`
+	}
+
+	a := &fingerprintConfig{Name: "app", Labels: map[string]string{"b": "2", "a": "1", "c": "3"}, Big: 1<<63 + 1}
+	b := &fingerprintConfig{Name: "app", Labels: map[string]string{"c": "3", "a": "1", "b": "2"}, Big: 1<<63 + 1}
+
+	fa, err := Fingerprint(a)
+	require.NoError(t, err)
+	assert.Len(t, fa, 64)
+
+	fb, err := Fingerprint(b)
+	require.NoError(t, err)
+	assert.Equal(t, fa, fb)
+
+	b.Big++
+	fb, err = Fingerprint(b)
+	require.NoError(t, err)
+	assert.NotEqual(t, fa, fb)
+
+	data, err := canonicalJSON(a)
+	require.NoError(t, err)
+	assert.Equal(t, `{"big":9223372036854775809,"labels":{"a":"1","b":"2","c":"3"},"name":"app"}`, string(data))
+}
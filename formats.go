@@ -0,0 +1,361 @@
+package confix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrFormatRegistered is returned by RegisterFormat when the extension already has a format.
+var ErrFormatRegistered = errors.New("format already registered")
+
+// DecodeFunc decodes configuration data read from r into v.
+type DecodeFunc func(r io.Reader, v any) error
+
+// EncodeFunc encodes v and writes the result to w.
+type EncodeFunc func(w io.Writer, v any) error
+
+// format describes how configuration files with a particular extension are decoded and encoded.
+type format struct {
+	// name is used in error messages
+	name string
+	// decode reads configuration data into a value according to the options
+	decode func(io.Reader, any, decodeOptions) error
+	// newEncoder returns an encoder writing to the writer, formatted according to the options
+	newEncoder func(io.Writer, EncoderOptions) encoder
+}
+
+// decodeOptions controls how the built-in decoders treat the data.
+type decodeOptions struct {
+	// strict makes decoding fail on keys that don't match any field
+	strict bool
+	// coerce makes strings given for time.Duration, ByteSize and time.Time fields be parsed where
+	// the decoder doesn't support them
+	coerce bool
+	// jsonComments makes comments and trailing commas in JSON data be ignored
+	jsonComments bool
+	// keyPolicy derives the keys of struct fields from their names instead of the tags of the format
+	keyPolicy func(string) string
+	// jsonMeta makes strict decoding accept the _meta key holding the write header of JSON data
+	jsonMeta bool
+	// source names the decoded data in warnings
+	source string
+	// logf receives warnings about the data, they are dropped when nil
+	logf func(format string, args ...any)
+}
+
+// EncoderOptions customizes the output of the built-in encoders.
+// Zero values keep the default formatting.
+type EncoderOptions struct {
+	// JSONIndent is the indentation of nested JSON values, two spaces by default
+	JSONIndent string
+	// YAMLIndent is the number of spaces used to indent nested YAML values, 2 by default
+	YAMLIndent int
+	// YAMLHeader is written as a comment followed by the "---" document start marker
+	// at the top of YAML files, e.g. "generated by confix - do not edit"
+	YAMLHeader string
+	// YAMLComments makes the comment tags of struct fields be written above their keys in YAML files
+	YAMLComments bool
+	// TOMLIndent is the indentation of nested TOML tables, two spaces by default
+	TOMLIndent string
+	// TOMLSortKeys makes TOML keys be written in alphabetical order instead of the order of struct fields
+	TOMLSortKeys bool
+}
+
+var (
+	formatsMu sync.RWMutex
+	// formats maps lowercase file extensions with the dot prefix to their formats
+	formats = map[string]format{}
+)
+
+func init() {
+	jsonFormat := format{
+		name: "json",
+		decode: func(r io.Reader, v any, opts decodeOptions) error {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if opts.jsonComments {
+				data = stripJSONComments(data)
+			}
+			r = bytes.NewReader(data)
+			rewritten := false
+			var values []codecValue
+			if opts.jsonMeta && opts.strict {
+				if r, err = dropJSONMeta(r); err != nil {
+					return err
+				}
+				rewritten = true
+			}
+			if hasTypeCodec(reflect.TypeOf(v)) {
+				if r, values, err = unmarshalJSONCodecs(r, v); err != nil {
+					return jsonErrorPosition(data, err)
+				}
+				rewritten = true
+			}
+			if opts.coerce {
+				if r, err = coerceJSON(r, v); err != nil {
+					return jsonErrorPosition(data, err)
+				}
+				rewritten = true
+			}
+			dec := json.NewDecoder(r)
+			if opts.strict {
+				dec.DisallowUnknownFields()
+			}
+			if err = dec.Decode(v); err != nil && !rewritten {
+				// offsets of rewritten data don't match the file
+				return jsonErrorPosition(data, err)
+			}
+			if err == nil {
+				assignCodecValues(v, values)
+			}
+			return err
+		},
+		newEncoder: func(w io.Writer, opts EncoderOptions) encoder {
+			indent := "  "
+			if opts.JSONIndent != "" {
+				indent = opts.JSONIndent
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", indent)
+			return enc
+		},
+	}
+	yamlFormat := format{
+		name: "yaml",
+		decode: func(r io.Reader, v any, opts decodeOptions) error {
+			var err error
+			var values []codecValue
+			if hasTypeCodec(reflect.TypeOf(v)) {
+				if r, values, err = unmarshalYAMLCodecs(r, v); err != nil {
+					return err
+				}
+			}
+			if opts.coerce {
+				if r, err = coerceYAML(r, v); err != nil {
+					return err
+				}
+			}
+			dec := yaml.NewDecoder(r)
+			dec.KnownFields(opts.strict)
+			if err = dec.Decode(v); err != nil {
+				return err
+			}
+			assignCodecValues(v, values)
+			return nil
+		},
+		newEncoder: func(w io.Writer, opts EncoderOptions) encoder {
+			indent := 2
+			if opts.YAMLIndent > 0 {
+				indent = opts.YAMLIndent
+			}
+			if opts.YAMLHeader != "" || opts.YAMLComments {
+				opts.YAMLIndent = indent
+				return &yamlEncoder{w: w, opts: opts}
+			}
+			enc := yaml.NewEncoder(w)
+			enc.SetIndent(indent)
+			return enc
+		},
+	}
+	tomlFormat := format{
+		name: "toml",
+		decode: func(r io.Reader, v any, opts decodeOptions) error {
+			var err error
+			var values []codecValue
+			if hasTypeCodec(reflect.TypeOf(v)) {
+				r, values, err = unmarshalTOMLCodecs(r, v)
+			}
+			var md toml.MetaData
+			if err == nil {
+				md, err = toml.NewDecoder(r).Decode(v)
+			}
+			if err != nil {
+				var pe toml.ParseError
+				if errors.As(err, &pe) {
+					return tomlParseError{pe}
+				}
+				return err
+			}
+			assignCodecValues(v, values)
+			undecoded := md.Undecoded()
+			switch {
+			case len(undecoded) == 0:
+			case opts.strict:
+				return fmt.Errorf("unknown keys: %s", joinKeys(undecoded))
+			case opts.logf != nil:
+				opts.logf("WARNING: keys not mapped to config fields in %s: %s", opts.source, joinKeys(undecoded))
+			}
+			return nil
+		},
+		newEncoder: func(w io.Writer, opts EncoderOptions) encoder {
+			enc := toml.NewEncoder(w)
+			if opts.TOMLIndent != "" {
+				enc.Indent = opts.TOMLIndent
+			}
+			if opts.TOMLSortKeys {
+				return &sortedTOMLEncoder{w: w, indent: enc.Indent}
+			}
+			return enc
+		},
+	}
+	iniFormat := format{
+		name: "ini",
+		decode: func(r io.Reader, v any, opts decodeOptions) error {
+			dec := newIniDecoder(r)
+			dec.keyPolicy = opts.keyPolicy
+			dec.strict = opts.strict
+			dec.coerce = opts.coerce
+			return dec.Decode(v)
+		},
+		newEncoder: func(w io.Writer, _ EncoderOptions) encoder {
+			return newIniEncoder(w)
+		},
+	}
+
+	for ext, f := range map[string]format{
+		jsonExt: jsonFormat,
+		yamlExt: yamlFormat,
+		ymlExt:  yamlFormat,
+		tomlExt: tomlFormat,
+		iniExt:  iniFormat,
+	} {
+		if err := registerFormat(ext, f, false); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterFormat registers a decoder and an encoder for configuration files with the extension ext,
+// e.g. ".conf". Registered formats are used for all configuration files, readers and writes.
+// It returns ErrFormatRegistered if the extension already has a format; use ReplaceFormat to override it.
+// RegisterFormat is safe to call from init functions and concurrently.
+func RegisterFormat(ext string, dec func(io.Reader, any) error, enc func(io.Writer, any) error) error {
+	return registerFormat(ext, newFormat(ext, dec, enc), false)
+}
+
+// ReplaceFormat registers a decoder and an encoder for the extension ext like RegisterFormat,
+// overriding a format already registered for it, including the built-in ones.
+func ReplaceFormat(ext string, dec func(io.Reader, any) error, enc func(io.Writer, any) error) error {
+	return registerFormat(ext, newFormat(ext, dec, enc), true)
+}
+
+// newFormat builds a format from user-provided decode and encode functions.
+func newFormat(ext string, dec DecodeFunc, enc EncodeFunc) format {
+	f := format{
+		name: strings.TrimPrefix(normalizeExt(ext), "."),
+	}
+	if dec != nil {
+		f.decode = func(r io.Reader, v any, _ decodeOptions) error {
+			return dec(r, v)
+		}
+	}
+	if enc != nil {
+		f.newEncoder = func(w io.Writer, _ EncoderOptions) encoder {
+			return encodeFuncEncoder{w: w, enc: enc}
+		}
+	}
+	return f
+}
+
+// registerFormat adds f to the registry under the extension ext.
+func registerFormat(ext string, f format, override bool) error {
+	ext = normalizeExt(ext)
+	if ext == "." || f.decode == nil || f.newEncoder == nil {
+		return fmt.Errorf("invalid format for extension %q", ext)
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	if _, ok := formats[ext]; ok && !override {
+		return fmt.Errorf("%w: %s", ErrFormatRegistered, ext)
+	}
+	formats[ext] = f
+	return nil
+}
+
+// lookupFormat returns the format registered for the extension ext, ignoring its case.
+func lookupFormat(ext string) (format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	f, ok := formats[normalizeExt(ext)]
+	return f, ok
+}
+
+// normalizeExt returns ext in lowercase with the dot prefix.
+func normalizeExt(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+}
+
+// joinKeys returns the TOML keys separated by commas.
+func joinKeys(keys []toml.Key) string {
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = k.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// encodeFuncEncoder adapts an EncodeFunc to the encoder interface.
+type encodeFuncEncoder struct {
+	w   io.Writer
+	enc EncodeFunc
+}
+
+func (e encodeFuncEncoder) Encode(v interface{}) error {
+	return e.enc(e.w, v)
+}
+
+// jsonErrorPosition adds the line and column to JSON syntax and type errors,
+// which only report the byte offset in data. The position is that of the last byte
+// read by the decoder, i.e. the offending character or the end of the mismatched value.
+func jsonErrorPosition(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+
+	offset = min(max(offset-1, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Errorf("line %d, column %d: %w", line, col, err)
+}
+
+// tomlParseError reports the column of toml.ParseError, which is left out of its message.
+type tomlParseError struct {
+	toml.ParseError
+}
+
+// Error returns the message of the parse error with its line and column.
+func (e tomlParseError) Error() string {
+	if e.LastKey == "" {
+		return fmt.Sprintf("toml: line %d, column %d: %s", e.Position.Line, e.Position.Col, e.Message)
+	}
+	return fmt.Sprintf("toml: line %d, column %d (last key %q): %s",
+		e.Position.Line, e.Position.Col, e.LastKey, e.Message)
+}
+
+// Unwrap returns the underlying toml.ParseError.
+func (e tomlParseError) Unwrap() error {
+	return e.ParseError
+}
@@ -0,0 +1,127 @@
+package confix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeKV decodes "a=value" lines into testConfig.
+func decodeKV(r io.Reader, v any) error {
+	cfg, ok := v.(*testConfig)
+	if !ok {
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if key, val, found := strings.Cut(sc.Text(), "="); found && key == "a" {
+			cfg.A = val
+		}
+	}
+	return sc.Err()
+}
+
+// encodeKV encodes testConfig as "a=value".
+func encodeKV(w io.Writer, v any) error {
+	cfg, ok := v.(*testConfig)
+	if !ok {
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	_, err := fmt.Fprintf(w, "a=%s\n", cfg.A)
+	return err
+}
+
+func unregisterFormat(t testing.TB, ext string) {
+	t.Helper()
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	delete(formats, ext)
+}
+
+func TestRegisterFormat(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		require.NoError(t, RegisterFormat("kv", decodeKV, encodeKV))
+		defer unregisterFormat(t, ".kv")
+
+		cfg := new(testConfig)
+		require.NoError(t, NewFromReader(cfg, strings.NewReader("a=value\n"), "kv"))
+		assert.Equal(t, "value", cfg.A)
+
+		fpath := path.Join(t.TempDir(), "config.kv")
+		c := &config[testConfig]{cfg: &testConfig{A: "written"}}
+		require.NoError(t, c.writeToFile(fpath))
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, "a=written\n", string(data))
+	})
+	t.Run("negative: duplicate", func(t *testing.T) {
+		err := RegisterFormat(".json", decodeKV, encodeKV)
+		assert.ErrorIs(t, err, ErrFormatRegistered)
+	})
+	t.Run("negative: invalid", func(t *testing.T) {
+		assert.Error(t, RegisterFormat("", decodeKV, encodeKV))
+		assert.Error(t, RegisterFormat(".nodec", nil, encodeKV))
+		assert.Error(t, RegisterFormat(".noenc", decodeKV, nil))
+	})
+	t.Run("positive: replace", func(t *testing.T) {
+		original, ok := lookupFormat(jsonExt)
+		require.True(t, ok)
+		defer func() {
+			require.NoError(t, registerFormat(jsonExt, original, true))
+		}()
+
+		require.NoError(t, ReplaceFormat(".json", decodeKV, encodeKV))
+		cfg := new(testConfig)
+		require.NoError(t, NewFromReader(cfg, strings.NewReader("a=value\n"), "json"))
+		assert.Equal(t, "value", cfg.A)
+	})
+}
+
+func TestDecodeErrorPosition(t *testing.T) {
+	type positionConfig struct {
+		A int `json:"a" yaml:"a" toml:"a" config:"a"`
+	}
+
+	for name, tc := range map[string]struct {
+		data     string
+		expected string
+	}{
+		"config.json": {
+			data:     "{\n  \"b\": 1,\n}",
+			expected: "error while decoding json from %s: line 3, column 1: invalid character '}'",
+		},
+		"types.json": {
+			data:     "{\n  \"a\": \"x\"\n}",
+			expected: "error while decoding json from %s: line 2, column 10: json: cannot unmarshal string",
+		},
+		"config.yaml": {
+			data:     "b: 1\na: [\n",
+			expected: "error while decoding yaml from %s: yaml: line 2",
+		},
+		"config.toml": {
+			data:     "b = 1\na = \n",
+			expected: "error while decoding toml from %s: toml: line 2, column 5 (last key \"a\")",
+		},
+		"config.ini": {
+			data:     "b = 1\na = x\n",
+			expected: "error while decoding ini from %s: ini: line 2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fpath := path.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(fpath, []byte(tc.data), 0o600))
+			require.NoError(t, os.Unsetenv(DirEnvName))
+			t.Setenv(FilePathEnvName, fpath)
+
+			err := New(new(positionConfig))
+			assert.ErrorContains(t, err, fmt.Sprintf(tc.expected, fpath))
+		})
+	}
+}
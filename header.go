@@ -0,0 +1,124 @@
+package confix
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// metaKey is the key holding the write header in JSON files written with jsonMeta.
+const metaKey = "_meta"
+
+// headerLines returns the lines of the write header: the lines set by WithWriteHeader followed by
+// the time of the write in UTC and the hostname, which is left out when it can't be determined.
+func (c *config[T]) headerLines() []string {
+	lines := append(append([]string{}, c.writeHeader...), "written: "+time.Now().UTC().Format(time.RFC3339))
+	if host, err := os.Hostname(); err == nil {
+		lines = append(lines, "host: "+host)
+	}
+	return lines
+}
+
+// addWriteHeader returns data, the configuration encoded for a file with the extension ext,
+// with the write header added: as comment lines followed by a blank line at the top of YAML,
+// TOML and INI files and, with jsonMeta, as the first key of JSON files. Other files are returned as is.
+func (c *config[T]) addWriteHeader(data []byte, ext string) ([]byte, error) {
+	f, _ := lookupFormat(ext)
+	switch f.name {
+	case "yaml", "toml", "ini":
+		var b bytes.Buffer
+		for _, line := range c.headerLines() {
+			for _, l := range strings.Split(line, "\n") {
+				b.WriteString(strings.TrimRight("# "+l, " ") + "\n")
+			}
+		}
+		b.WriteString("\n")
+		b.Write(data)
+		return b.Bytes(), nil
+	case "json":
+		if !c.jsonMeta || !bytes.HasPrefix(data, []byte("{")) {
+			return data, nil
+		}
+		meta, err := json.Marshal(c.headerLines())
+		if err != nil {
+			return nil, err
+		}
+		indent := "  "
+		if c.encoderOptions.JSONIndent != "" {
+			indent = c.encoderOptions.JSONIndent
+		}
+		body := data[1:]
+		sep := ","
+		if bytes.Equal(bytes.TrimSpace(body), []byte("}")) {
+			sep = "\n"
+		}
+
+		var b bytes.Buffer
+		b.WriteString("{\n" + indent + `"` + metaKey + `": `)
+		b.Write(meta)
+		b.WriteString(sep)
+		b.Write(body)
+		return b.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
+
+// stripWriteHeader returns data, the contents of a file with the extension ext, without
+// the write header added by addWriteHeader, so that files differing only in the header compare equal
+// and the header isn't repeated when YAML files are updated in place.
+// Comment blocks without the "written:" line of the header are kept.
+func (c *config[T]) stripWriteHeader(data []byte, ext string) []byte {
+	f, _ := lookupFormat(ext)
+	switch f.name {
+	case "yaml", "toml", "ini":
+		rest, stamped := data, false
+		for bytes.HasPrefix(rest, []byte("#")) {
+			line, next, _ := bytes.Cut(rest, []byte("\n"))
+			stamped = stamped || bytes.HasPrefix(line, []byte("# written: "))
+			rest = next
+		}
+		if !stamped {
+			return data
+		}
+		return bytes.TrimPrefix(rest, []byte("\n"))
+	case "json":
+		first, rest, ok := bytes.Cut(data, []byte("\n"))
+		if !c.jsonMeta || !ok || !bytes.Equal(first, []byte("{")) {
+			return data
+		}
+		line, rest, _ := bytes.Cut(rest, []byte("\n"))
+		if !bytes.HasPrefix(bytes.TrimSpace(line), []byte(`"`+metaKey+`": `)) {
+			return data
+		}
+		return append([]byte("{\n"), rest...)
+	default:
+		return data
+	}
+}
+
+// dropJSONMeta returns a reader of the JSON data read from r without the _meta key of the top-level
+// object, so that strict decoding accepts files written with jsonMeta. Other values are returned as is.
+func dropJSONMeta(r io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(data, &m); err != nil {
+		return bytes.NewReader(data), nil
+	}
+	if _, ok := m[metaKey]; !ok {
+		return bytes.NewReader(data), nil
+	}
+	delete(m, metaKey)
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
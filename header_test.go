@@ -0,0 +1,103 @@
+package confix
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWriteHeader(t *testing.T) {
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	written := `# written: \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z\n`
+
+	newConfig := func(t *testing.T, opts ...Option[testConfig]) *config[testConfig] {
+		c := &config[testConfig]{cfg: &testConfig{A: "value"}}
+		for _, opt := range append([]Option[testConfig]{WithWriteHeader[testConfig]([]string{"app v1.2.3"})}, opts...) {
+			require.NoError(t, opt.apply(c))
+		}
+		return c
+	}
+
+	for ext, body := range map[string]string{
+		".yaml": "a: value\n",
+		".toml": "a = \"value\"\n",
+		".ini":  "a = value\n",
+	} {
+		t.Run("positive: comments "+ext, func(t *testing.T) {
+			fpath := path.Join(t.TempDir(), "config"+ext)
+			require.NoError(t, newConfig(t).writeToFile(fpath))
+
+			data, err := os.ReadFile(fpath)
+			require.NoError(t, err)
+			pattern := "^# app v1.2.3\n" + written + "# host: " + regexp.QuoteMeta(host) + "\n\n" + regexp.QuoteMeta(body) + "$"
+			assert.Regexp(t, pattern, string(data))
+
+			cfg := new(testConfig)
+			require.NoError(t, Unmarshal(data, ext, cfg, WithStrictDecoding[testConfig]()))
+			assert.Equal(t, "value", cfg.A)
+		})
+	}
+	t.Run("positive: json", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, newConfig(t).writeToFile(fpath))
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, "{\n  \"a\": \"value\"\n}\n", string(data))
+	})
+	t.Run("positive: json meta", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, newConfig(t, WithJSONMeta[testConfig]()).writeToFile(fpath))
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), "{\n  \"_meta\": [\"app v1.2.3\","), string(data))
+
+		var m struct {
+			Meta []string `json:"_meta"`
+			A    string   `json:"a"`
+		}
+		require.NoError(t, json.Unmarshal(data, &m))
+		assert.Equal(t, "value", m.A)
+		if assert.Len(t, m.Meta, 3) {
+			assert.Equal(t, "host: "+host, m.Meta[2])
+		}
+
+		assert.Error(t, Unmarshal(data, ".json", new(testConfig), WithStrictDecoding[testConfig]()))
+		cfg := new(testConfig)
+		require.NoError(t, Unmarshal(data, ".json", cfg, WithStrictDecoding[testConfig](), WithJSONMeta[testConfig]()))
+		assert.Equal(t, "value", cfg.A)
+	})
+	t.Run("positive: unchanged", func(t *testing.T) {
+		for _, ext := range []string{".yaml", ".json"} {
+			fpath := path.Join(t.TempDir(), "config"+ext)
+			c := newConfig(t, WithJSONMeta[testConfig](), WithSkipUnchanged[testConfig]())
+			require.NoError(t, c.writeToFile(fpath))
+			past := time.Now().Add(-time.Hour).Truncate(time.Second)
+			require.NoError(t, os.Chtimes(fpath, past, past))
+
+			require.NoError(t, c.writeToFile(fpath))
+			fi, err := os.Stat(fpath)
+			require.NoError(t, err)
+			assert.Equal(t, past, fi.ModTime(), ext)
+		}
+	})
+	t.Run("positive: yaml round trip", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.yaml")
+		c := newConfig(t, WithYAMLRoundTrip[testConfig]())
+		require.NoError(t, c.writeToFile(fpath))
+		c.cfg.A = "changed"
+		require.NoError(t, c.writeToFile(fpath))
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(data), "# written: "))
+		assert.True(t, strings.HasSuffix(string(data), "\n\na: changed\n"), string(data))
+	})
+}
@@ -0,0 +1,343 @@
+package confix
+
+import (
+	"bufio"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// iniEncoder writes a struct to INI format. Top-level scalar fields and slices of scalars become keys,
+// nested structs and maps with string keys become sections named by their config tags joined with dots.
+// Structs with codecs or implementing encoding.TextMarshaler, such as time.Time, are written as values,
+// pointers as the values they point to, and nil pointers are left out.
+type iniEncoder struct {
+	w io.Writer
+	// keyPolicy derives the keys of fields without a config tag from their names
+	keyPolicy func(string) string
+}
+
+// newIniEncoder returns a new encoder that writes to w.
+func newIniEncoder(w io.Writer) *iniEncoder {
+	return &iniEncoder{w: w}
+}
+
+// Encode writes the INI encoding of v to the underlying writer.
+func (e *iniEncoder) Encode(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("ini: unsupported type: %T", v)
+	}
+
+	bw := bufio.NewWriter(e.w)
+	if err := encodeIniSection(bw, rv, "", e.keyPolicy); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// encodeIniSection writes the scalar fields of v as keys and then every nested struct and map
+// as a separate section. Keys are derived by iniKey with the policy.
+func encodeIniSection(w *bufio.Writer, v reflect.Value, section string, policy func(string) string) error {
+	t := v.Type()
+	var nested []int
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := iniKey(f, policy)
+		if !f.IsExported() || key == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if !isTextType(fv.Type()) && (fv.Kind() == reflect.Struct || fv.Kind() == reflect.Map) {
+			nested = append(nested, i)
+			continue
+		}
+		s, err := formatIniValue(fv)
+		if err != nil {
+			return fmt.Errorf("ini: field %s: %w", f.Name, err)
+		}
+		if _, err = fmt.Fprintf(w, "%s = %s\n", key, s); err != nil {
+			return err
+		}
+	}
+
+	for _, i := range nested {
+		name := iniKey(t.Field(i), policy)
+		if section != "" {
+			name = section + "." + name
+		}
+		fv := reflect.Indirect(v.Field(i))
+		if fv.Kind() == reflect.Map && fv.Len() == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\n[%s]\n", name); err != nil {
+			return err
+		}
+		if fv.Kind() == reflect.Map {
+			if err := encodeIniMap(w, fv); err != nil {
+				return fmt.Errorf("ini: field %s: %w", t.Field(i).Name, err)
+			}
+			continue
+		}
+		if err := encodeIniSection(w, fv, name, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeIniMap writes the entries of the map v, which must have string keys and scalar values,
+// as keys of a section in the order of their keys.
+func encodeIniMap(w *bufio.Writer, v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("unsupported field type: %s", v.Type())
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	for _, k := range keys {
+		key := k.String()
+		if key == "" || strings.TrimSpace(key) != key || strings.ContainsAny(key, "=[]") ||
+			strings.ContainsFunc(key, unicode.IsControl) || strings.HasPrefix(key, ";") || strings.HasPrefix(key, "#") {
+			return fmt.Errorf("key %q can't be written to INI files", key)
+		}
+		s, err := formatIniValue(v.MapIndex(k))
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		if _, err = fmt.Fprintf(w, "%s = %s\n", key, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatIniValue returns the textual representation of a scalar value, of the result of the codec
+// of its type or of its MarshalText method. Strings with surrounding whitespace, quotes or control
+// characters such as line breaks are quoted to survive decoding. Slices are written as comma-separated
+// values, which fails for elements that would be split or quoted.
+func formatIniValue(v reflect.Value) (string, error) {
+	if c, ok := lookupTypeCodec(v.Type()); ok {
+		data, err := c.marshal(v.Interface())
+		if err != nil {
+			return "", err
+		}
+		dv := reflect.ValueOf(data)
+		if !dv.IsValid() || dv.Type() == v.Type() {
+			return "", fmt.Errorf("codec of %s returned %T", v.Type(), data)
+		}
+		return formatIniValue(dv)
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(textMarshalerType) && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return formatIniValue(reflect.ValueOf(string(text)))
+	}
+	if v.Type() == durationType {
+		return fmt.Sprint(v.Interface()), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "", nil
+		}
+		return formatIniValue(v.Elem())
+	case reflect.String:
+		s := v.String()
+		if strings.TrimSpace(s) != s || strings.HasPrefix(s, `"`) || strings.ContainsFunc(s, unicode.IsControl) {
+			return strconv.Quote(s), nil
+		}
+		return s, nil
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			s, err := formatIniValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			if s == "" || strings.Contains(s, ",") || strings.HasPrefix(s, `"`) {
+				return "", fmt.Errorf("element %d %q can't be written as a comma-separated value", i, v.Index(i).Interface())
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ", "), nil
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	default:
+		return "", fmt.Errorf("unsupported field type: %s", v.Type())
+	}
+}
+
+// iniDecoder reads INI data into a struct. Keys and sections are matched
+// against config tags of the struct fields.
+type iniDecoder struct {
+	r io.Reader
+	// strict makes unknown sections and keys an error
+	strict bool
+	// coerce makes values of time.Time fields be parsed as local datetimes and dates as well
+	coerce bool
+	// keyPolicy derives the keys of fields without a config tag from their names
+	keyPolicy func(string) string
+}
+
+// newIniDecoder returns a new decoder that reads from r.
+func newIniDecoder(r io.Reader) *iniDecoder {
+	return &iniDecoder{r: r}
+}
+
+// Decode reads INI data and stores the values in the struct pointed to by v.
+// Unknown sections and keys are ignored unless the decoder is strict.
+func (d *iniDecoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ini: unsupported type: %T", v)
+	}
+
+	section := rv.Elem()
+	sc := bufio.NewScanner(d.r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case text == "", strings.HasPrefix(text, ";"), strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			section = lookupIniSection(rv.Elem(), name, d.keyPolicy)
+			if d.strict && !section.IsValid() {
+				return fmt.Errorf("ini: line %d: unknown section %q", line, name)
+			}
+			continue
+		}
+
+		key, val, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("ini: line %d: missing '='", line)
+		}
+		if !section.IsValid() {
+			continue
+		}
+		if section.Kind() == reflect.Map {
+			e := reflect.New(section.Type().Elem()).Elem()
+			if err := setFieldFromString(e, unquoteIniValue(strings.TrimSpace(val))); err != nil {
+				return fmt.Errorf("ini: line %d: %w", line, err)
+			}
+			section.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)).Convert(section.Type().Key()), e)
+			continue
+		}
+		fv, ok := lookupIniField(section, strings.TrimSpace(key), d.keyPolicy)
+		if !ok {
+			if d.strict {
+				return fmt.Errorf("ini: line %d: unknown key %q", line, strings.TrimSpace(key))
+			}
+			continue
+		}
+		fv = allocIniPointer(fv)
+		val = unquoteIniValue(strings.TrimSpace(val))
+		if d.coerce && fv.Type() == timeType {
+			tm, err := parseTime(val)
+			if err != nil {
+				return fmt.Errorf("ini: line %d: %w", line, err)
+			}
+			fv.Set(reflect.ValueOf(tm))
+			continue
+		}
+		if err := setFieldFromString(fv, val); err != nil {
+			return fmt.Errorf("ini: line %d: %w", line, err)
+		}
+	}
+	return sc.Err()
+}
+
+// lookupIniSection resolves a dotted section name to a nested struct of root, or to a map with string keys
+// named by the last part, which is created when it is nil. It returns the zero Value when there is no such field.
+func lookupIniSection(root reflect.Value, name string, policy func(string) string) reflect.Value {
+	v := root
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		fv, ok := lookupIniField(v, strings.TrimSpace(part), policy)
+		if ok {
+			fv = allocIniPointer(fv)
+		}
+		if ok && i == len(parts)-1 && fv.Kind() == reflect.Map && fv.Type().Key().Kind() == reflect.String {
+			if fv.IsNil() {
+				fv.Set(reflect.MakeMap(fv.Type()))
+			}
+			return fv
+		}
+		if !ok || fv.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		v = fv
+	}
+	return v
+}
+
+// allocIniPointer returns the value the pointer v points to, allocating it when v is nil,
+// and v itself when it isn't a pointer or its type has a codec.
+func allocIniPointer(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Pointer {
+		return v
+	}
+	if _, ok := lookupTypeCodec(v.Type()); ok {
+		return v
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Elem()
+}
+
+// lookupIniField returns the exported field of the struct v whose key derived by iniKey matches key.
+func lookupIniField(v reflect.Value, key string, policy func(string) string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if name := iniKey(f, policy); name != "-" && strings.EqualFold(name, key) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// iniKey returns the key of the field f in INI files: the name of its config tag or, without one,
+// the field name passed through the policy when it is set and the field name itself otherwise.
+func iniKey(f reflect.StructField, policy func(string) string) string {
+	key, _ := parseConfigTag(f)
+	if tag, _, _ := strings.Cut(f.Tag.Get(configTagName), ","); tag == "" && policy != nil {
+		return policy(f.Name)
+	}
+	return key
+}
+
+// unquoteIniValue removes surrounding double quotes written by formatIniValue.
+func unquoteIniValue(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
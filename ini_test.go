@@ -0,0 +1,219 @@
+package confix
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type iniTestConfig struct {
+	Name    string        `config:"name"`
+	Port    int           `config:"port"`
+	Debug   bool          `config:"debug"`
+	Timeout time.Duration `config:"timeout"`
+	DB      struct {
+		Host string `config:"host"`
+		Pool struct {
+			Size uint `config:"size"`
+		} `config:"pool"`
+	} `config:"db"`
+}
+
+func TestIniDecoder(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		data := `
+; comment
+# another comment
+name = " app "
+port = 8080
+debug = true
+timeout = 5s
+
+[db]
+host = localhost
+unknown = ignored
+
+[db.pool]
+size = 10
+
+[unknown]
+key = value
+`
+		cfg := new(iniTestConfig)
+		require.NoError(t, newIniDecoder(strings.NewReader(data)).Decode(cfg))
+		assert.Equal(t, " app ", cfg.Name)
+		assert.Equal(t, 8080, cfg.Port)
+		assert.True(t, cfg.Debug)
+		assert.Equal(t, 5*time.Second, cfg.Timeout)
+		assert.Equal(t, "localhost", cfg.DB.Host)
+		assert.Equal(t, uint(10), cfg.DB.Pool.Size)
+	})
+	t.Run("negative: missing separator", func(t *testing.T) {
+		err := newIniDecoder(strings.NewReader("name")).Decode(new(iniTestConfig))
+		assert.Error(t, err)
+	})
+	t.Run("negative: invalid value", func(t *testing.T) {
+		err := newIniDecoder(strings.NewReader("port = abc")).Decode(new(iniTestConfig))
+		assert.Error(t, err)
+	})
+	t.Run("negative: not a struct", func(t *testing.T) {
+		var s string
+		assert.Error(t, newIniDecoder(strings.NewReader("")).Decode(&s))
+	})
+}
+
+func TestIniEncoder(t *testing.T) {
+	cfg := &iniTestConfig{Name: "app", Port: 8080, Timeout: time.Minute}
+	cfg.DB.Host = "localhost"
+	cfg.DB.Pool.Size = 3
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, newIniEncoder(buf).Encode(cfg))
+	assert.Equal(t, `name = app
+port = 8080
+debug = false
+timeout = 1m0s
+
+[db]
+host = localhost
+
+[db.pool]
+size = 3
+`, buf.String())
+
+	decoded := new(iniTestConfig)
+	require.NoError(t, newIniDecoder(buf).Decode(decoded))
+	assert.Equal(t, cfg, decoded)
+}
+
+func TestIniEncoderRoundTrip(t *testing.T) {
+	type listConfig struct {
+		Note   string            `config:"note"`
+		Hosts  []string          `config:"hosts"`
+		Ports  []int             `config:"ports"`
+		Limits map[string]int    `config:"limits"`
+		Labels map[string]string `config:"labels"`
+	}
+
+	cfg := &listConfig{
+		Note:   "a\nb\tc",
+		Hosts:  []string{"a", "b"},
+		Ports:  []int{80, 443},
+		Limits: map[string]int{"b": 2, "a": 1},
+		Labels: map[string]string{"env": "multi\nline"},
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, newIniEncoder(buf).Encode(cfg))
+	assert.Equal(t, `note = "a\nb\tc"
+hosts = a, b
+ports = 80, 443
+
+[limits]
+a = 1
+b = 2
+
+[labels]
+env = "multi\nline"
+`, buf.String())
+
+	decoded := new(listConfig)
+	require.NoError(t, newIniDecoder(buf).Decode(decoded))
+	assert.Equal(t, cfg, decoded)
+
+	t.Run("negative: element with comma", func(t *testing.T) {
+		err := newIniEncoder(&bytes.Buffer{}).Encode(&listConfig{Hosts: []string{"a,b"}})
+		assert.ErrorContains(t, err, "field Hosts")
+		assert.ErrorContains(t, err, "comma-separated")
+	})
+	t.Run("negative: map key", func(t *testing.T) {
+		err := newIniEncoder(&bytes.Buffer{}).Encode(&listConfig{Limits: map[string]int{"a=b": 1}})
+		assert.ErrorContains(t, err, "field Limits")
+	})
+	t.Run("negative: map of structs", func(t *testing.T) {
+		err := newIniEncoder(&bytes.Buffer{}).Encode(&struct {
+			M map[string]struct{ A int } `config:"m"`
+		}{M: map[string]struct{ A int }{"x": {}}})
+		assert.ErrorContains(t, err, "unsupported field type")
+	})
+}
+
+func TestIniEncoderTextAndPointers(t *testing.T) {
+	type dbConfig struct {
+		Host string `config:"host"`
+	}
+	type textConfig struct {
+		Name    string    `config:"name"`
+		Created time.Time `config:"created"`
+		Size    ByteSize  `config:"size"`
+		Port    *int      `config:"port"`
+		Missing *int      `config:"missing"`
+		DB      *dbConfig `config:"db"`
+		Backup  *dbConfig `config:"backup"`
+	}
+
+	port := 8080
+	cfg := &textConfig{
+		Name:    "a",
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Size:    2 * MiB,
+		Port:    &port,
+		DB:      &dbConfig{Host: "db"},
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, newIniEncoder(buf).Encode(cfg))
+	assert.Equal(t, `name = a
+created = 2024-01-02T03:04:05Z
+size = 2MiB
+port = 8080
+
+[db]
+host = db
+`, buf.String())
+
+	decoded := new(textConfig)
+	require.NoError(t, newIniDecoder(buf).Decode(decoded))
+	assert.Equal(t, cfg, decoded)
+}
+
+func TestNew_Ini(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.ini")
+	require.NoError(t, os.WriteFile(fpath, []byte("name = app\n\n[db]\nhost = db\n"), 0o600))
+	require.NoError(t, SetConfigPath(fpath))
+	defer func() {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+	}()
+
+	cfg := new(iniTestConfig)
+	require.NoError(t, New(cfg))
+	assert.Equal(t, "app", cfg.Name)
+	assert.Equal(t, "db", cfg.DB.Host)
+}
+
+func TestIniDecoderStrict(t *testing.T) {
+	type cfg struct {
+		A      string `config:"a"`
+		Nested struct {
+			B string `config:"b"`
+		} `config:"nested"`
+	}
+
+	dec := newIniDecoder(strings.NewReader("a = 1\n[unknown]\nb = 2\n"))
+	dec.strict = true
+	assert.ErrorContains(t, dec.Decode(&cfg{}), `unknown section "unknown"`)
+
+	dec = newIniDecoder(strings.NewReader("[nested]\nc = 2\n"))
+	dec.strict = true
+	assert.ErrorContains(t, dec.Decode(&cfg{}), `unknown key "c"`)
+
+	dec = newIniDecoder(strings.NewReader("a = 1\n[nested]\nb = 2\n"))
+	dec.strict = true
+	v := &cfg{}
+	require.NoError(t, dec.Decode(v))
+	assert.Equal(t, "2", v.Nested.B)
+}
@@ -0,0 +1,70 @@
+package confix
+
+// stripJSONComments returns a copy of the JSON data with // line comments, /* */ block comments
+// and trailing commas before closing brackets replaced by spaces. Line breaks are kept,
+// so that line and column numbers in decoding errors still match the file.
+// An unterminated block comment is left as is for the decoder to report.
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			i = skipJSONString(out, i)
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := i + 2
+			for end+1 < len(out) && (out[end] != '*' || out[end+1] != '/') {
+				end++
+			}
+			if end+1 >= len(out) {
+				return out
+			}
+			for ; i <= end+1; i++ {
+				if out[i] != '\n' && out[i] != '\r' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '"':
+			i = skipJSONString(out, i)
+		case ',':
+			j := i + 1
+			for j < len(out) && isJSONSpace(out[j]) {
+				j++
+			}
+			if j < len(out) && (out[j] == '}' || out[j] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+	return out
+}
+
+// skipJSONString returns the index of the quote closing the JSON string starting at data[i],
+// or the last index of data when the string is unterminated.
+func skipJSONString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(data) - 1
+}
+
+// isJSONSpace reports whether b is insignificant whitespace in JSON.
+func isJSONSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,65 @@
+package confix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripJSONComments(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data     string
+		expected string
+	}{
+		"line comment":         {data: "{\"a\": 1 // note\n}", expected: "{\"a\": 1        \n}"},
+		"block comment":        {data: "{/* a\nb */\"a\": 1}", expected: "{    \n    \"a\": 1}"},
+		"trailing comma":       {data: "{\"a\": [1, 2,],\n}", expected: "{\"a\": [1, 2 ] \n}"},
+		"comment then comma":   {data: "{\"a\": 1, // last\n}", expected: "{\"a\": 1         \n}"},
+		"slashes in string":    {data: `{"a": "http://x/*y*/", "b": "\"//"}`, expected: `{"a": "http://x/*y*/", "b": "\"//"}`},
+		"comma in string":      {data: `{"a": ",}"}`, expected: `{"a": ",}"}`},
+		"unterminated block":   {data: `{"a": 1 /* oops}`, expected: `{"a": 1 /* oops}`},
+		"no comments or comma": {data: `{"a": [1, 2]}`, expected: `{"a": [1, 2]}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(stripJSONComments([]byte(tc.data))))
+		})
+	}
+}
+
+func TestWithJSONComments(t *testing.T) {
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	data := "{\n  // the value\n  \"a\": \"value\", /* trailing */\n}\n"
+
+	t.Run("positive: file", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(data), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithJSONComments[testConfig](), WithSyncingConfigToFiles[testConfig]()))
+		assert.Equal(t, "value", cfg.A)
+
+		written, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, "{\n  \"a\": \"value\"\n}\n", string(written))
+	})
+	t.Run("positive: sniffing", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "app.conf")
+		require.NoError(t, os.WriteFile(fpath, []byte("// header\n"+data), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithJSONComments[testConfig](), WithFormatSniffing[testConfig]()))
+		assert.Equal(t, "value", cfg.A)
+	})
+	t.Run("negative: without option", func(t *testing.T) {
+		assert.ErrorContains(t, Unmarshal([]byte(data), ".json", new(testConfig)), "line 2, column 3")
+	})
+	t.Run("negative: error position", func(t *testing.T) {
+		err := Unmarshal([]byte("{\n  // note\n  \"a\": x\n}"), ".json", new(testConfig), WithJSONComments[testConfig]())
+		assert.ErrorContains(t, err, "line 3, column 8")
+	})
+}
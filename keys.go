@@ -0,0 +1,374 @@
+package confix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// policyField is a struct field named by a key naming policy, with the path of keys
+// under which the format encodes it.
+type policyField struct {
+	typ    reflect.Type
+	native []string
+}
+
+// policyFields returns the fields of the struct type t by their keys under the naming policy:
+// the name of the config tag when it is set and the policy applied to the field name otherwise.
+// Fields tagged `config:"-"` are left out, embedded structs without a config tag are flattened.
+// format is the name of a built-in format selecting the native keys.
+func policyFields(t reflect.Type, policy func(string) string, format string) map[string]policyField {
+	fields := map[string]policyField{}
+	collectPolicyFields(t, policy, format, nil, fields)
+	return fields
+}
+
+// collectPolicyFields adds the fields of the struct type t to fields, with native paths
+// starting with prefix. Fields of outer structs win over the fields of embedded ones.
+func collectPolicyFields(t reflect.Type, policy func(string) string, format string, prefix []string, fields map[string]policyField) {
+	var embedded []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		native, _, ok := nativeKey(f, format)
+		tag, _, _ := strings.Cut(f.Tag.Get(configTagName), ",")
+		if !ok || tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, i)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		key := tag
+		if key == "" {
+			key = policy(f.Name)
+		}
+		if _, ok = fields[key]; key == "" || ok {
+			continue
+		}
+		fields[key] = policyField{typ: f.Type, native: append(slices.Clone(prefix), native)}
+	}
+
+	for _, i := range embedded {
+		f := t.Field(i)
+		native, flatten, _ := nativeKey(f, format)
+		path := prefix
+		if !flatten {
+			path = append(slices.Clone(prefix), native)
+		}
+		collectPolicyFields(f.Type, policy, format, path, fields)
+	}
+}
+
+// nativeKey returns the key under which the format encodes the struct field f, as set by the tag
+// named after the format, and whether the format flattens the field into its parent as an embedded struct.
+// It reports false for fields the format skips.
+func nativeKey(f reflect.StructField, format string) (string, bool, bool) {
+	name, opts, _ := strings.Cut(f.Tag.Get(format), ",")
+	if name == "-" || !f.IsExported() && !f.Anonymous {
+		return "", false, false
+	}
+
+	switch format {
+	case "yaml":
+		flatten := hasTagOption(strings.Split(opts, ","), "inline") && f.Type.Kind() == reflect.Struct
+		if !flatten && !f.IsExported() {
+			// yaml.v3 can't set unexported embedded structs nested under their own key
+			return "", false, false
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		return name, flatten, true
+	default:
+		flatten := f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct
+		if name == "" {
+			name = f.Name
+		}
+		return name, flatten, true
+	}
+}
+
+// toNativeKeys returns data decoded into generic values with the keys of structs of the type t
+// named by the policy replaced by the keys of the format. Other keys of structs are dropped,
+// so that keys matching only the tags of the format are not decoded, and appended to unknown.
+// path names the value in unknown.
+func toNativeKeys(data any, t reflect.Type, policy func(string) string, format, path string, unknown *[]string) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch d := data.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Map:
+			for k, e := range d {
+				d[k] = toNativeKeys(e, t.Elem(), policy, format, joinPath(path, k), unknown)
+			}
+		case reflect.Struct:
+			fields := policyFields(t, policy, format)
+			out := make(map[string]any, len(d))
+			for k, e := range d {
+				f, ok := fields[k]
+				if !ok {
+					*unknown = append(*unknown, joinPath(path, k))
+					continue
+				}
+				setPath(out, f.native, toNativeKeys(e, f.typ, policy, format, joinPath(path, k), unknown))
+			}
+			return out
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, e := range d {
+				d[i] = toNativeKeys(e, t.Elem(), policy, format, fmt.Sprintf("%s[%d]", path, i), unknown)
+			}
+		}
+	case []map[string]any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			s := make([]any, len(d))
+			for i, e := range d {
+				s[i] = toNativeKeys(e, t.Elem(), policy, format, fmt.Sprintf("%s[%d]", path, i), unknown)
+			}
+			return s
+		}
+	}
+	return data
+}
+
+// toPolicyKeys returns data, a value of the type t encoded and decoded into generic values,
+// with the keys of the format replaced by the keys named by the policy. Keys of fields
+// without a policy key are dropped.
+func toPolicyKeys(data any, t reflect.Type, policy func(string) string, format string) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch d := data.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Map:
+			for k, e := range d {
+				d[k] = toPolicyKeys(e, t.Elem(), policy, format)
+			}
+		case reflect.Struct:
+			out := make(map[string]any, len(d))
+			for k, f := range policyFields(t, policy, format) {
+				if e, ok := getPath(d, f.native); ok {
+					out[k] = toPolicyKeys(e, f.typ, policy, format)
+				}
+			}
+			return out
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, e := range d {
+				d[i] = toPolicyKeys(e, t.Elem(), policy, format)
+			}
+		}
+	case []map[string]any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			s := make([]any, len(d))
+			for i, e := range d {
+				s[i] = toPolicyKeys(e, t.Elem(), policy, format)
+			}
+			return s
+		}
+	}
+	return data
+}
+
+// setPath stores v in m under the keys, creating intermediate maps.
+func setPath(m map[string]any, keys []string, v any) {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = v
+}
+
+// getPath returns the value found in m by following the keys.
+func getPath(m map[string]any, keys []string) (any, bool) {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	v, ok := m[keys[len(keys)-1]]
+	return v, ok
+}
+
+// toPolicyYAMLNode replaces the keys of mappings of n encoded from a value of the type t
+// with the keys named by the policy, lifting the keys of embedded structs the YAML encoder nests.
+// Comments and the order of keys are kept, keys of fields without a policy key are dropped.
+func toPolicyYAMLNode(n *yaml.Node, t reflect.Type, policy func(string) string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			toPolicyYAMLNode(c, t, policy)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for _, c := range n.Content {
+			toPolicyYAMLNode(c, t.Elem(), policy)
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 1; i < len(n.Content); i += 2 {
+				toPolicyYAMLNode(n.Content[i], t.Elem(), policy)
+			}
+		case reflect.Struct:
+			keys := map[string]string{}
+			fields := policyFields(t, policy, "yaml")
+			for k, f := range fields {
+				keys[strings.Join(f.native, "\x00")] = k
+			}
+			n.Content = renameYAMLMapping(n.Content, nil, keys, fields, policy)
+		}
+	}
+}
+
+// renameYAMLMapping returns the key and value nodes of content, a mapping nested under the native keys
+// of prefix, with the keys renamed by keys, which maps native paths joined with NUL to policy keys.
+// Mappings of embedded structs nested by the encoder are lifted into the result.
+func renameYAMLMapping(content []*yaml.Node, prefix []string, keys map[string]string, fields map[string]policyField, policy func(string) string) []*yaml.Node {
+	var out []*yaml.Node
+	for i := 0; i+1 < len(content); i += 2 {
+		path := append(slices.Clone(prefix), content[i].Value)
+		joined := strings.Join(path, "\x00")
+		if k, ok := keys[joined]; ok {
+			content[i].Value = k
+			toPolicyYAMLNode(content[i+1], fields[k].typ, policy)
+			out = append(out, content[i], content[i+1])
+			continue
+		}
+		if content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		for native := range keys {
+			if strings.HasPrefix(native, joined+"\x00") {
+				out = append(out, renameYAMLMapping(content[i+1].Content, path, keys, fields, policy)...)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// decodeWithKeyPolicy reads data of the built-in format from r and returns a reader of the same data
+// with the keys named by the key policy of opts for fields of v replaced by the keys of the format.
+// Other keys of structs are dropped, failing strict decoding. Data of other formats is returned as is.
+func decodeWithKeyPolicy(r io.Reader, format string, v any, opts decodeOptions) (io.Reader, error) {
+	var data any
+	switch format {
+	case "json":
+		if opts.jsonComments {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			r = bytes.NewReader(stripJSONComments(b))
+		}
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		if err := yaml.NewDecoder(r).Decode(&data); err != nil {
+			if errors.Is(err, io.EOF) {
+				return bytes.NewReader(nil), nil
+			}
+			return nil, err
+		}
+	case "toml":
+		m := map[string]any{}
+		if _, err := toml.NewDecoder(r).Decode(&m); err != nil {
+			return nil, err
+		}
+		data = m
+	default:
+		return r, nil
+	}
+
+	var unknown []string
+	data = toNativeKeys(data, reflect.TypeOf(v), opts.keyPolicy, format, "", &unknown)
+	if len(unknown) > 0 && opts.strict {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case "json":
+		err = json.NewEncoder(buf).Encode(data)
+	case "yaml":
+		err = yaml.NewEncoder(buf).Encode(data)
+	case "toml":
+		err = toml.NewEncoder(buf).Encode(data)
+	}
+	return buf, err
+}
+
+// encodeWithKeyPolicy writes v with the encoder e of the format of the extension ext, with keys
+// named by the policy instead of the tags of the format. JSON and TOML keys are written in
+// alphabetical order. INI encoders are expected to apply the policy themselves.
+func encodeWithKeyPolicy(e encoder, ext string, v any, opts EncoderOptions, policy func(string) string) error {
+	f, _ := lookupFormat(ext)
+	switch f.name {
+	case "json", "toml":
+		buf := &bytes.Buffer{}
+		if err := encodeWithCodecs(f.newEncoder(buf, EncoderOptions{}), ext, v, EncoderOptions{}); err != nil {
+			return err
+		}
+		var data any
+		if f.name == "json" {
+			dec := json.NewDecoder(buf)
+			dec.UseNumber()
+			if err := dec.Decode(&data); err != nil {
+				return err
+			}
+		} else {
+			m := map[string]any{}
+			if _, err := toml.NewDecoder(buf).Decode(&m); err != nil {
+				return err
+			}
+			data = m
+		}
+		return e.Encode(toPolicyKeys(data, reflect.TypeOf(v), policy, f.name))
+	case "yaml":
+		node, err := encodeYAMLNode(v, opts)
+		if err != nil {
+			return err
+		}
+		toPolicyYAMLNode(node, reflect.TypeOf(v), policy)
+		return e.Encode(node)
+	default:
+		return encodeWithCodecs(e, ext, v, opts)
+	}
+}
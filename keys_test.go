@@ -0,0 +1,102 @@
+package confix
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snakeCase converts a Go field name like "MaxConns" to "max_conns".
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type policyEmbedded struct {
+	LogLevel string
+}
+
+type policyServer struct {
+	ListenAddr string `json:"addr" yaml:"addr" toml:"addr"`
+}
+
+type policyConfig struct {
+	policyEmbedded `yaml:",inline"`
+	MaxConns       int          `json:"mc" yaml:"mc" toml:"mc"`
+	Server         policyServer `json:"srv" yaml:"srv" toml:"srv"`
+	Override       string       `config:"custom"`
+	Hidden         string       `config:"-"`
+}
+
+func TestWithKeyNamingPolicy(t *testing.T) {
+	expected := &policyConfig{
+		policyEmbedded: policyEmbedded{LogLevel: "debug"},
+		MaxConns:       10,
+		Server:         policyServer{ListenAddr: ":8080"},
+		Override:       "x",
+	}
+	opts := []Option[policyConfig]{WithKeyNamingPolicy[policyConfig](snakeCase), WithStrictDecoding[policyConfig]()}
+
+	for ext, data := range map[string]string{
+		".json": `{"log_level": "debug", "max_conns": 10, "server": {"listen_addr": ":8080"}, "custom": "x"}`,
+		".yaml": "log_level: debug\nmax_conns: 10\nserver:\n  listen_addr: \":8080\"\ncustom: x\n",
+		".toml": "log_level = \"debug\"\nmax_conns = 10\ncustom = \"x\"\n[server]\nlisten_addr = \":8080\"\n",
+		".ini":  "max_conns = 10\ncustom = x\n[server]\nlisten_addr = :8080\n",
+	} {
+		t.Run("positive: decode "+ext, func(t *testing.T) {
+			cfg := new(policyConfig)
+			require.NoError(t, Unmarshal([]byte(data), ext, cfg, opts...))
+			want := *expected
+			if ext == ".ini" {
+				// the INI format has no embedded structs
+				want.LogLevel = ""
+			}
+			assert.Equal(t, &want, cfg)
+		})
+	}
+
+	for ext, data := range map[string]string{
+		".json": "{\n  \"custom\": \"x\",\n  \"log_level\": \"debug\",\n  \"max_conns\": 10,\n  \"server\": {\n    \"listen_addr\": \":8080\"\n  }\n}\n",
+		".yaml": "log_level: debug\nmax_conns: 10\nserver:\n  listen_addr: :8080\ncustom: x\n",
+		".toml": "custom = \"x\"\nlog_level = \"debug\"\nmax_conns = 10\n\n[server]\n  listen_addr = \":8080\"\n",
+	} {
+		t.Run("positive: encode "+ext, func(t *testing.T) {
+			c := &config[policyConfig]{cfg: expected}
+			require.NoError(t, WithKeyNamingPolicy[policyConfig](snakeCase).apply(c))
+			buf := &bytes.Buffer{}
+			require.NoError(t, c.encode(buf, ext))
+			assert.Equal(t, data, buf.String())
+		})
+	}
+	t.Run("positive: encode .ini", func(t *testing.T) {
+		c := &config[policyConfig]{cfg: &policyConfig{MaxConns: 10, Server: policyServer{ListenAddr: ":8080"}}}
+		require.NoError(t, WithKeyNamingPolicy[policyConfig](snakeCase).apply(c))
+		buf := &bytes.Buffer{}
+		require.NoError(t, c.encode(buf, ".ini"))
+		assert.Equal(t, "max_conns = 10\ncustom = \n\n[server]\nlisten_addr = :8080\n", buf.String())
+	})
+	t.Run("negative: tag keys are unknown", func(t *testing.T) {
+		err := Unmarshal([]byte(`{"mc": 10, "server": {"addr": ":80"}}`), ".json", new(policyConfig), opts...)
+		assert.ErrorContains(t, err, "unknown keys: mc, server.addr")
+
+		cfg := new(policyConfig)
+		require.NoError(t, Unmarshal([]byte(`{"mc": 10}`), ".json", cfg, WithKeyNamingPolicy[policyConfig](snakeCase)))
+		assert.Zero(t, cfg.MaxConns)
+	})
+	t.Run("negative: nil policy", func(t *testing.T) {
+		assert.Error(t, Unmarshal([]byte(`{}`), ".json", new(policyConfig), WithKeyNamingPolicy[policyConfig](nil)))
+	})
+}
@@ -0,0 +1,26 @@
+package confix
+
+import "log"
+
+// Logger is the interface used by confix to report diagnostics.
+// It can be implemented by adapters around structured loggers.
+type Logger interface {
+	Logf(format string, args ...any)
+}
+
+// stdLogger is the default Logger writing through the standard log package.
+type stdLogger struct{}
+
+func (stdLogger) Logf(format string, args ...any) {
+	log.Printf(format, args...)
+}
+
+// logf reports a diagnostic message through the configured logger,
+// falling back to the standard logger when none is set.
+func (c *config[T]) logf(format string, args ...any) {
+	if c.logger == nil {
+		stdLogger{}.Logf(format, args...)
+		return
+	}
+	c.logger.Logf(format, args...)
+}
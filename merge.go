@@ -0,0 +1,127 @@
+package confix
+
+import "reflect"
+
+// mergeInto deep-merges src into dst. Only non-zero values of src overwrite values of dst,
+// nested structs and pointers are merged field by field and maps are merged key by key.
+// Slices and other values are replaced as a whole.
+func mergeInto[T any](dst, src *T) {
+	mergeValues(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+// mergeValues deep-merges src into the settable value dst of the same type.
+func mergeValues(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		if !hasOnlyExportedFields(src.Type()) {
+			// structs like time.Time can't be merged field by field
+			if !src.IsZero() {
+				dst.Set(src)
+			}
+			return
+		}
+		for i := 0; i < src.NumField(); i++ {
+			mergeValues(dst.Field(i), src.Field(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		}
+		for _, k := range src.MapKeys() {
+			e := reflect.New(src.Type().Elem()).Elem()
+			if dv := dst.MapIndex(k); dv.IsValid() {
+				e.Set(dv)
+			}
+			mergeValues(e, src.MapIndex(k))
+			dst.SetMapIndex(k, e)
+		}
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(src.Type().Elem()))
+		}
+		mergeValues(dst.Elem(), src.Elem())
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
+
+// hasOnlyExportedFields reports whether every field of the struct type t is exported.
+func hasOnlyExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
+
+// deepCopy returns a copy of cfg sharing no maps, slices or pointers with it, so that
+// changes to either are not visible in the other. Unexported fields are copied shallowly.
+func deepCopy[T any](cfg *T) *T {
+	cp := new(T)
+	reflect.ValueOf(cp).Elem().Set(copyValue(reflect.ValueOf(cfg).Elem()))
+	return cp
+}
+
+// copyValue returns a deep copy of v for deepCopy.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(copyValue(v.Elem()))
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(copyValue(v.Elem()))
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				cp.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			cp.SetMapIndex(k, copyValue(v.MapIndex(k)))
+		}
+		return cp
+	default:
+		return v
+	}
+}
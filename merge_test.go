@@ -0,0 +1,88 @@
+package confix
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mergeTestConfig struct {
+	Name   string            `json:"name" yaml:"name"`
+	Port   int               `json:"port" yaml:"port"`
+	Hosts  []string          `json:"hosts" yaml:"hosts"`
+	Labels map[string]string `json:"labels" yaml:"labels"`
+	Since  time.Time         `json:"since" yaml:"since"`
+	DB     struct {
+		Host string `json:"host" yaml:"host"`
+		User string `json:"user" yaml:"user"`
+	} `json:"db" yaml:"db"`
+	Limits *struct {
+		Max int `json:"max" yaml:"max"`
+		Min int `json:"min" yaml:"min"`
+	} `json:"limits" yaml:"limits"`
+	Nested map[string]map[string]int `json:"nested" yaml:"nested"`
+}
+
+func TestMergeInto(t *testing.T) {
+	dst := &mergeTestConfig{
+		Name:   "base",
+		Port:   80,
+		Hosts:  []string{"a", "b"},
+		Labels: map[string]string{"env": "dev", "team": "core"},
+		Nested: map[string]map[string]int{"x": {"a": 1}},
+	}
+	dst.DB.Host = "localhost"
+	dst.DB.User = "root"
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := &mergeTestConfig{
+		Port:   8080,
+		Hosts:  []string{"c"},
+		Labels: map[string]string{"env": "prod"},
+		Since:  since,
+		Nested: map[string]map[string]int{"x": {"b": 2}},
+	}
+	src.DB.Host = "db"
+	src.Limits = &struct {
+		Max int `json:"max" yaml:"max"`
+		Min int `json:"min" yaml:"min"`
+	}{Max: 10}
+
+	mergeInto(dst, src)
+
+	assert.Equal(t, "base", dst.Name)
+	assert.Equal(t, 8080, dst.Port)
+	assert.Equal(t, []string{"c"}, dst.Hosts)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "core"}, dst.Labels)
+	assert.Equal(t, since, dst.Since)
+	assert.Equal(t, "db", dst.DB.Host)
+	assert.Equal(t, "root", dst.DB.User)
+	if assert.NotNil(t, dst.Limits) {
+		assert.Equal(t, 10, dst.Limits.Max)
+	}
+	assert.Equal(t, map[string]map[string]int{"x": {"a": 1, "b": 2}}, dst.Nested)
+	assert.Equal(t, map[string]map[string]int{"x": {"b": 2}}, src.Nested, "src must not be modified")
+}
+
+func TestLoad_DeepMerge(t *testing.T) {
+	dir := t.TempDir()
+	base := path.Join(dir, "config.json")
+	overlay := path.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(base, []byte(`{"db": {"host": "localhost", "user": "root"}, "labels": {"team": "core"}}`), 0o600))
+	require.NoError(t, os.WriteFile(overlay, []byte("db:\n  host: db\nlabels:\n  env: prod\n"), 0o600))
+
+	c := &config[mergeTestConfig]{
+		settings: settings{mergeAll: true},
+		cfg:      new(mergeTestConfig),
+		paths:    []string{base, overlay},
+	}
+	require.NoError(t, c.load(context.Background()))
+	assert.Equal(t, "db", c.cfg.DB.Host)
+	assert.Equal(t, "root", c.cfg.DB.User)
+	assert.Equal(t, map[string]string{"team": "core", "env": "prod"}, c.cfg.Labels)
+}
@@ -0,0 +1,80 @@
+package confix
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// versionKey is the config key of the field holding the version of the configuration format.
+const versionKey = "version"
+
+// errNoVersionField is returned by WithMigrations for configurations without a version field.
+var errNoVersionField = errors.New(`no integer field tagged config:"version"`)
+
+// migrate applies the migrations to cfg from the version stored in its version field up to the latest one.
+// The migration stored under version v upgrades the configuration from v to v+1;
+// the version field is updated after every migration.
+func migrate[T any](cfg *T, migs map[int]func(*T) error) error {
+	field, ok := versionField(reflect.ValueOf(cfg).Elem())
+	if !ok {
+		return errNoVersionField
+	}
+
+	latest := 0
+	for v := range migs {
+		latest = max(latest, v+1)
+	}
+
+	for v := versionOf(field); v < latest; v++ {
+		f, ok := migs[v]
+		if !ok {
+			return fmt.Errorf("error while migrating config from version %d: no migration", v)
+		}
+		if err := f(cfg); err != nil {
+			return fmt.Errorf("error while migrating config from version %d: %w", v, err)
+		}
+		setVersion(field, v+1)
+	}
+	return nil
+}
+
+// versionField returns the integer field of the struct v with the version config key.
+func versionField(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if key, _ := parseConfigTag(f); key != versionKey {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// versionOf returns the value of the integer version field v.
+func versionOf(v reflect.Value) int {
+	if v.CanInt() {
+		return int(v.Int())
+	}
+	return int(v.Uint())
+}
+
+// setVersion stores version in the integer version field v.
+func setVersion(v reflect.Value, version int) {
+	if v.CanInt() {
+		v.SetInt(int64(version))
+		return
+	}
+	v.SetUint(uint64(version))
+}
@@ -0,0 +1,98 @@
+package confix
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type migrateTestConfig struct {
+	Version int    `config:"version" json:"version"`
+	Addr    string `config:"addr" json:"addr"`
+	Host    string `config:"host" json:"host,omitempty"`
+	Port    int    `config:"port" json:"port,omitempty"`
+}
+
+var testMigrations = map[int]func(*migrateTestConfig) error{
+	0: func(c *migrateTestConfig) error {
+		if c.Port == 0 {
+			c.Port = 80
+		}
+		return nil
+	},
+	1: func(c *migrateTestConfig) error {
+		c.Addr = c.Host + ":" + strconv.Itoa(c.Port)
+		c.Host, c.Port = "", 0
+		return nil
+	},
+}
+
+func TestMigrate(t *testing.T) {
+	t.Run("positive: from the first version", func(t *testing.T) {
+		cfg := &migrateTestConfig{Host: "db"}
+		require.NoError(t, migrate(cfg, testMigrations))
+		assert.Equal(t, &migrateTestConfig{Version: 2, Addr: "db:80"}, cfg)
+	})
+	t.Run("positive: from an intermediate version", func(t *testing.T) {
+		cfg := &migrateTestConfig{Version: 1, Host: "db", Port: 54}
+		require.NoError(t, migrate(cfg, testMigrations))
+		assert.Equal(t, &migrateTestConfig{Version: 2, Addr: "db:54"}, cfg)
+	})
+	t.Run("positive: latest version is untouched", func(t *testing.T) {
+		cfg := &migrateTestConfig{Version: 2, Addr: "db:80"}
+		require.NoError(t, migrate(cfg, testMigrations))
+		assert.Equal(t, &migrateTestConfig{Version: 2, Addr: "db:80"}, cfg)
+	})
+	t.Run("positive: unsigned version", func(t *testing.T) {
+		type unsignedConfig struct {
+			V uint8 `config:"version"`
+		}
+		cfg := &unsignedConfig{}
+		require.NoError(t, migrate(cfg, map[int]func(*unsignedConfig) error{
+			0: func(*unsignedConfig) error { return nil },
+		}))
+		assert.Equal(t, uint8(1), cfg.V)
+	})
+	t.Run("negative: failed migration keeps the version", func(t *testing.T) {
+		errBroken := errors.New("broken")
+		cfg := &migrateTestConfig{}
+		err := migrate(cfg, map[int]func(*migrateTestConfig) error{
+			0: testMigrations[0],
+			1: func(*migrateTestConfig) error { return errBroken },
+		})
+		assert.ErrorIs(t, err, errBroken)
+		assert.ErrorContains(t, err, "from version 1")
+		assert.Equal(t, 1, cfg.Version)
+	})
+	t.Run("negative: missing migration", func(t *testing.T) {
+		cfg := &migrateTestConfig{}
+		err := migrate(cfg, map[int]func(*migrateTestConfig) error{1: testMigrations[1]})
+		assert.ErrorContains(t, err, "from version 0")
+	})
+	t.Run("negative: no version field", func(t *testing.T) {
+		assert.ErrorIs(t, migrate(&testConfig{}, map[int]func(*testConfig) error{}), errNoVersionField)
+	})
+}
+
+func TestWithMigrations(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"host": "db", "port": 54}`), 0o600))
+
+	cfg := new(migrateTestConfig)
+	require.NoError(t, New(cfg,
+		WithMigrations(testMigrations),
+		WithSyncingConfigToFiles[migrateTestConfig](),
+	))
+	assert.Equal(t, &migrateTestConfig{Version: 2, Addr: "db:54"}, cfg)
+
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version": 2, "addr": "db:54"}`, string(data))
+}
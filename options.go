@@ -1,39 +1,654 @@
 package confix
 
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
 // Option represents a configuration option that can be applied to modify the behavior
 // of a configuration instance.
 type Option[T any] interface {
 	apply(*config[T]) error
 }
 
-// afterOptionFunc is a function type that implements the Option interface
-// for applying configuration modifications after initialization.
-type afterOptionFunc[T any] func(*config[T]) error
+// phase defines when an option applied after loading runs. Options run phase by phase,
+// in the order they are passed within a phase, regardless of their order across phases.
+type phase int
+
+const (
+	// phaseTransform options modify the loaded configuration
+	phaseTransform phase = iota
+	// phaseValidate options check the transformed configuration
+	phaseValidate
+	// phasePersist options write the validated configuration
+	phasePersist
+)
+
+// afterOption implements the Option interface for applying configuration modifications
+// after the configuration is loaded, in the phase it belongs to.
+type afterOption[T any] struct {
+	phase phase
+	f     func(*config[T]) error
+}
+
+func (o afterOption[T]) apply(cfg *config[T]) error {
+	return o.f(cfg)
+}
+
+// beforeOptionFunc is a function type that implements the Option interface
+// for configuring the instance before configuration files are loaded, i.e. in the load phase.
+type beforeOptionFunc[T any] func(*config[T]) error
 
-func (f afterOptionFunc[T]) apply(cfg *config[T]) error {
+func (f beforeOptionFunc[T]) apply(cfg *config[T]) error {
 	return f(cfg)
 }
 
 // WithValidation creates an Option that applies a validation function to the configuration.
 // The validation function is called after the configuration is initialized.
 func WithValidation[T any](f func(cfg *T) error) Option[T] {
-	return afterOptionFunc[T](func(c *config[T]) error {
+	return afterOption[T]{phase: phaseValidate, f: func(c *config[T]) error {
 		return f(c.cfg)
-	})
+	}}
+}
+
+// WithValidations creates an Option that applies all validation functions to the configuration
+// and returns their errors joined, so that every problem is reported at once.
+func WithValidations[T any](fns ...func(cfg *T) error) Option[T] {
+	return afterOption[T]{phase: phaseValidate, f: func(c *config[T]) error {
+		return Validate(c.cfg, fns...)
+	}}
+}
+
+// WithTagValidation creates an Option that checks fields against the rules of their validate tags,
+// e.g. `validate:"min=1,max=65535"` or `validate:"oneof=debug info warn error"`.
+// Every violation is reported, wrapping ErrInvalidField.
+func WithTagValidation[T any]() Option[T] {
+	return afterOption[T]{phase: phaseValidate, f: func(c *config[T]) error {
+		return checkTags(reflect.ValueOf(c.cfg).Elem(), "")
+	}}
+}
+
+// WithOnLoad creates an Option that calls f with the loaded configuration, e.g. to derive computed fields.
+// Unlike WithValidation, which is meant for checking, f is expected to modify the configuration.
+// It runs in the transform phase, i.e. before validation and writing options.
+func WithOnLoad[T any](f func(cfg *T) error) Option[T] {
+	return afterOption[T]{phase: phaseTransform, f: func(c *config[T]) error {
+		return f(c.cfg)
+	}}
 }
 
 // WithWritingConfigToFile creates an Option that writes the configuration to the specified file.
 // The file path is provided as a parameter.
 func WithWritingConfigToFile[T any](f string) Option[T] {
-	return afterOptionFunc[T](func(c *config[T]) error {
+	return afterOption[T]{phase: phasePersist, f: func(c *config[T]) error {
 		return c.writeToFile(f)
-	})
+	}}
+}
+
+// WithWritingConfigToFiles creates an Option that writes the configuration to each of the specified files,
+// regardless of the discovered ones. The format of each file is chosen by its extension;
+// files are written concurrently and write errors are aggregated like for WithSyncingConfigToFiles.
+func WithWritingConfigToFiles[T any](paths ...string) Option[T] {
+	return afterOption[T]{phase: phasePersist, f: func(c *config[T]) error {
+		return c.writeToPaths(paths)
+	}}
 }
 
 // WithSyncingConfigToFiles creates an Option that synchronizes the configuration
-// with all registered configuration files.
+// with the configuration files that were loaded, each in its own format.
+// Files that were found but not decoded are not written.
 func WithSyncingConfigToFiles[T any]() Option[T] {
-	return afterOptionFunc[T](func(c *config[T]) error {
+	return afterOption[T]{phase: phasePersist, f: func(c *config[T]) error {
 		return c.writeToFiles()
+	}}
+}
+
+// WithEnvOverrides creates an Option that overrides configuration fields with values of environment variables.
+// Variable names consist of the prefix and the uppercased config tag of the field joined with underscores,
+// e.g. APP_A for the field tagged `config:"a"` and prefix "APP". Nested structs extend the prefix with their key.
+func WithEnvOverrides[T any](prefix string) Option[T] {
+	return afterOption[T]{phase: phaseTransform, f: func(c *config[T]) error {
+		return applyEnvOverrides(reflect.ValueOf(c.cfg).Elem(), prefix)
+	}}
+}
+
+// WithKeyNamingPolicy creates an Option that derives the keys of configuration fields in every format
+// from their Go names with policy, e.g. a function converting "MaxConns" to "max_conns", instead of
+// the json, yaml and toml tags. The name of the config tag overrides the policy for a field,
+// fields tagged `config:"-"` are neither decoded nor written. Other keys, including those matching
+// only the tags, are ignored or, with WithStrictDecoding, reported. Written JSON and TOML files
+// have their keys in alphabetical order.
+func WithKeyNamingPolicy[T any](policy func(fieldName string) string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if policy == nil {
+			return fmt.Errorf("invalid key naming policy: nil")
+		}
+		c.keyPolicy = policy
+		return nil
+	})
+}
+
+// WithWriteHeader creates an Option that stamps written configuration files with a header: the lines,
+// e.g. the name and version of the application, followed by the time of the write in UTC and the hostname.
+// The header is written as comments at the top of YAML, TOML and INI files, and under the _meta key
+// of JSON files with WithJSONMeta. Files differing only in the time of the header count as unchanged
+// for WithSkipUnchanged.
+func WithWriteHeader[T any](lines []string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.writeHeader = append([]string{}, lines...)
+		return nil
+	})
+}
+
+// WithJSONMeta creates an Option that makes WithWriteHeader store the header of JSON files
+// as an array of lines under the _meta key, the first key of the object. JSON has no comments,
+// so JSON files get no header without it. Strict decoding accepts the key.
+func WithJSONMeta[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.jsonMeta = true
+		return nil
+	})
+}
+
+// WithErrorOnEmpty creates an Option that makes an empty configuration file fail initialization
+// and reloads with ErrEmptyConfigFile when the file was given explicitly, i.e. by FilePathEnvName,
+// WithConfigFile, the standard input or WithOverlay, e.g. because someone truncated it.
+// Empty files found in configuration directories are still skipped.
+func WithErrorOnEmpty[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.errorOnEmpty = true
+		return nil
+	})
+}
+
+// WithLogger creates an Option that routes diagnostic messages of confix to the provided logger
+// instead of the standard log package.
+func WithLogger[T any](l Logger) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.logger = l
+		return nil
+	})
+}
+
+// WithRequireFile creates an Option that makes initialization fail with ErrNoConfigFound
+// when no configuration file is found.
+func WithRequireFile[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.requireFile = true
+		return nil
+	})
+}
+
+// WithEnvExpansion creates an Option that expands ${VAR} and $VAR references to environment variables
+// in every string value of the configuration. Unset variables expand to an empty string
+// unless a default is provided with the ${VAR:-default} form.
+// References are expanded in the decoded values rather than in the file contents,
+// so they behave the same way in every format.
+func WithEnvExpansion[T any]() Option[T] {
+	return afterOption[T]{phase: phaseTransform, f: func(c *config[T]) error {
+		return walkStrings(reflect.ValueOf(c.cfg), func(s string) (string, error) {
+			return expandEnv(s), nil
+		})
+	}}
+}
+
+// WithEmbeddedDefaults creates an Option that decodes the file name from fsys, e.g. an embed.FS,
+// into the configuration before configuration files are loaded, so files on disk override it.
+// The decoder is chosen by the extension of name.
+func WithEmbeddedDefaults[T any](fsys fs.FS, name string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+
+		return decode(f, path.Ext(name), c.cfg, c.decodeOptions(name))
+	})
+}
+
+// WithFormatPriority creates an Option that orders discovered configuration files by their extension.
+// The order lists extensions (e.g. ".json" or "json") from the lowest to the highest priority;
+// files with higher priority are loaded later and override values of the others.
+func WithFormatPriority[T any](order []string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.formatPriority = order
+		return nil
+	})
+}
+
+// WithMergeAllFound creates an Option that decodes every found configuration file in order
+// and deep-merges them, so that non-zero values of later files override earlier ones
+// and maps are merged key by key. By default only the highest-priority file is loaded.
+func WithMergeAllFound[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.mergeAll = true
+		return nil
+	})
+}
+
+// WithOverlay creates an Option that deep-merges the given files on top of the loaded configuration
+// in order, e.g. config.prod.yaml over config.yaml. The decoder of each file is chosen by its extension
+// and missing files are skipped unless WithRequireOverlays is set.
+func WithOverlay[T any](paths ...string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.overlays = append(c.overlays, paths...)
+		return nil
+	})
+}
+
+// WithRequireOverlays creates an Option that makes initialization fail when files given with WithOverlay
+// don't exist instead of skipping them. The returned *MissingFilesError names every missing file.
+func WithRequireOverlays[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.requireOverlays = true
+		return nil
+	})
+}
+
+// WithRemoteSource creates an Option that fetches configuration with an HTTP GET request to url
+// and decodes it before local files, so that local files override it. The decoder is chosen by
+// the Content-Type of the response (application/json, application/yaml or application/toml),
+// falling back to format, e.g. "json". Responses with non-2xx status fail initialization.
+// The client allows setting timeouts and authentication; http.DefaultClient is used when it is nil.
+func WithRemoteSource[T any](url, format string, client *http.Client) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.remoteSources = append(c.remoteSources, httpSource(client, url, format))
+		return nil
+	})
+}
+
+// WithEtcd creates an Option that decodes the value stored at key in etcd before local files,
+// so that local files override it. The decoder is chosen by format, e.g. "yaml", falling back
+// to the extension of key, e.g. "app/config.yaml". A missing key fails initialization.
+// Use OnEtcdChange to reload the configuration when the key changes.
+func WithEtcd[T any](client EtcdClient, key, format string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if client == nil {
+			return fmt.Errorf("invalid etcd client: nil")
+		}
+		c.remoteSources = append(c.remoteSources, etcdSource(client, key, format))
+		return nil
+	})
+}
+
+// WithConsul creates an Option that decodes the value stored at key in the Consul KV store before local files,
+// so that local files override it. The decoder is chosen by format, e.g. "yaml", falling back
+// to the extension of key, e.g. "app/config.yaml". A missing key fails initialization.
+// Use OnConsulChange to reload the configuration when the key changes.
+func WithConsul[T any](client ConsulClient, key, format string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if client == nil {
+			return fmt.Errorf("invalid consul client: nil")
+		}
+		c.remoteSources = append(c.remoteSources, consulSource(client, key, format))
+		return nil
+	})
+}
+
+// WithJSONSchema creates an Option that validates the configuration against the JSON Schema
+// after loading. The configuration is marshaled to JSON, so json tags define property names.
+// The returned error lists every violating path.
+func WithJSONSchema[T any](schema []byte) Option[T] {
+	return afterOption[T]{phase: phaseValidate, f: func(c *config[T]) error {
+		return validateJSONSchema(schema, c.cfg)
+	}}
+}
+
+// WithSkipUnchanged creates an Option that makes writing options leave files untouched
+// when their contents already match the encoded configuration. Skipped writes are reported
+// through the logger. Files encrypted by WithDecryption are compared decrypted.
+func WithSkipUnchanged[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.skipUnchanged = true
+		return nil
+	})
+}
+
+// WithBackupOnWrite creates an Option that makes writing options copy an existing file
+// to its path with the suffix appended, e.g. "config.json.bak", before replacing it.
+// The suffix defaults to ".bak" when empty. Files that don't exist yet are not backed up.
+func WithBackupOnWrite[T any](suffix string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if suffix == "" {
+			suffix = ".bak"
+		}
+		c.backupSuffix = suffix
+		return nil
+	})
+}
+
+// WithDryRun creates an Option that makes writing options encode the configuration without
+// touching any files. Planned writes are reported through the logger and are returned in
+// Result.PlannedWrites by NewWithResult.
+func WithDryRun[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.dryRun = true
+		return nil
+	})
+}
+
+// WithEncoderOptions creates an Option that customizes the formatting of files written by
+// writing options, e.g. tabs for JSON or an indent of 4 for YAML. Unset fields keep the defaults.
+func WithEncoderOptions[T any](opts EncoderOptions) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.encoderOptions = opts
+		return nil
+	})
+}
+
+// WithDebounce creates an Option that makes Watch and WatchChan coalesce the file events
+// arriving within d into a single reload instead of within DefaultDebounce.
+func WithDebounce[T any](d time.Duration) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid debounce window: %s", d)
+		}
+		c.debounce = d
+		return nil
+	})
+}
+
+// WithNoCreate creates an Option that keeps a missing file set through FilePathEnvName
+// from being created and filled with the configuration. The file is skipped instead,
+// so that the values already in the configuration remain, which suits read-only filesystems.
+func WithNoCreate[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.noCreate = true
+		return nil
+	})
+}
+
+// WithXDGSearch creates an Option that looks for configuration files in $XDG_CONFIG_HOME/<appName>,
+// or ~/.config/<appName> when XDG_CONFIG_HOME is unset, when neither FilePathEnvName nor DirEnvName
+// is set. These files have the lowest priority, so files next to the executable or in the current
+// directory override them.
+func WithXDGSearch[T any](appName string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.xdgAppName = appName
+		return nil
+	})
+}
+
+// WithDecryption creates an Option that decrypts configuration encrypted with AES-GCM using the key,
+// which must be 16, 24 or 32 bytes long. Files produced by EncryptFile are decrypted as a whole
+// before decoding, string fields tagged `config:"...,encrypted"` holding values produced by
+// EncryptValue are decrypted after loading. Writing options encrypt the same fields and files
+// that are already encrypted, so that round trips keep the data encrypted at rest.
+func WithDecryption[T any](key []byte) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return fmt.Errorf("error while creating cipher: %w", err)
+		}
+		c.aead = aead
+		return nil
+	})
+}
+
+// WithSecretResolver creates an Option that replaces string values of the form "secret://<ref>"
+// with the result of resolve called with <ref>, e.g. a lookup in a secret store.
+// A resolution error aborts initialization. It runs in the transform phase, so validation
+// sees the resolved values, while writing options write the references instead of the secrets.
+func WithSecretResolver[T any](resolve func(ref string) (string, error)) Option[T] {
+	return afterOption[T]{phase: phaseTransform, f: func(c *config[T]) error {
+		refs, err := resolveSecrets(reflect.ValueOf(c.cfg), resolve)
+		if err != nil {
+			return err
+		}
+		if c.secretRefs == nil {
+			c.secretRefs = refs
+			return nil
+		}
+		for path, r := range refs {
+			c.secretRefs[path] = r
+		}
+		return nil
+	}}
+}
+
+// WithStrictDecoding creates an Option that makes decoding of configuration files fail on keys
+// that don't match any field of the configuration, e.g. misspelled ones. The error names the keys.
+// Formats registered with RegisterFormat are decoded as usual.
+func WithStrictDecoding[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.strict = true
+		return nil
+	})
+}
+
+// WithJSONComments creates an Option that makes JSON configuration files, readers and stdin
+// accept // and /* */ comments and trailing commas, as written by people annotating their configs.
+// Files are still written as standard JSON, so syncing drops the comments.
+func WithJSONComments[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.jsonComments = true
+		return nil
+	})
+}
+
+// WithSyncPrimaryOnly creates an Option that makes WithSyncingConfigToFiles and Store.Set write
+// only the highest-priority configuration file instead of every found one. When no file exists,
+// the highest-priority candidate path is created.
+func WithSyncPrimaryOnly[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.syncPrimaryOnly = true
+		return nil
+	})
+}
+
+// WithWriteConcurrency creates an Option that limits the number of files written at once
+// when syncing to n, 4 by default. With n <= 1 files are written one after another.
+func WithWriteConcurrency[T any](n int) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.writeConcurrency = max(n, 1)
+		return nil
+	})
+}
+
+// WithGlob creates an Option that deep-merges drop-in files matching the pattern, e.g. "conf.d/*.yaml",
+// after the configuration files and before overlays. Matches are merged in lexical order, so "10-base.yaml"
+// is overridden by "20-local.yaml". Matches with unsupported extensions are skipped.
+func WithGlob[T any](pattern string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("error while parsing glob %s: %w", pattern, err)
+		}
+		c.globs = append(c.globs, pattern)
+		return nil
+	})
+}
+
+// WithStringCoercion creates an Option that makes strings like "30s" given for time.Duration fields
+// and like "10MB" given for ByteSize fields in JSON files be parsed instead of failing decoding.
+// YAML, TOML and INI files accept such strings without the option.
+// Strings given for time.Time fields are parsed in JSON, YAML and INI files the way TOML reads
+// datetimes: RFC 3339, local datetimes like "2024-05-01T10:00:00" and local dates like "2024-05-01",
+// the local ones as UTC. Invalid strings fail initialization with an error naming the field.
+func WithStringCoercion[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.coerce = true
+		return nil
+	})
+}
+
+// WithMigrations creates an Option that upgrades configurations written in older formats.
+// The version is read from the integer field tagged `config:"version"`; the migration stored under
+// version v upgrades the configuration from v to v+1. Migrations run in the transform phase,
+// in order from the loaded version up to the latest one, and the version field is updated after each.
+// Combine it with WithSyncingConfigToFiles to write the migrated configuration back.
+func WithMigrations[T any](migs map[int]func(cfg *T) error) Option[T] {
+	return afterOption[T]{phase: phaseTransform, f: func(c *config[T]) error {
+		return migrate(c.cfg, migs)
+	}}
+}
+
+// WithStdin creates an Option that reads the configuration from the standard input
+// decoded as format, one of "json", "yaml", "yml", "toml", "ini" or the extension of a registered format.
+// Stdin replaces configuration files unless FilePathEnvName is set; a "-" entry of FilePathEnvName
+// also stands for stdin and requires this option or WithFormatSniffing. Stdin is never written back.
+func WithStdin[T any](format string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.stdinFormat = format
+		return nil
+	})
+}
+
+// WithConfigDir creates an Option that looks for configuration files in dir, or in a list of directories
+// separated by os.PathListSeparator, the same way as DirEnvName but without touching the environment.
+// It takes precedence over DirEnvName and FilePathEnvName; WithConfigFile takes precedence over it.
+func WithConfigDir[T any](dir string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.configDir = dir
+		return nil
+	})
+}
+
+// WithConfigFile creates an Option that loads the configuration file at path, or a list of files
+// separated by os.PathListSeparator, the same way as FilePathEnvName but without touching the environment.
+// It takes precedence over DirEnvName and FilePathEnvName.
+func WithConfigFile[T any](path string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.configFile = path
+		return nil
+	})
+}
+
+// WithDirEnvNames creates an Option that reads the configuration directories from the first set
+// of the environment variables names, in priority order, instead of DirEnvName,
+// e.g. WithDirEnvNames("APP_CONFIG_DIR", "CONFIG_DIR"). Include DirEnvName in names to keep it.
+func WithDirEnvNames[T any](names ...string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.env.dir = firstEnv(names)
+		return nil
+	})
+}
+
+// WithFileEnvNames creates an Option that reads the configuration files from the first set
+// of the environment variables names, in priority order, instead of FilePathEnvName.
+// Include FilePathEnvName in names to keep it.
+func WithFileEnvNames[T any](names ...string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.env.filePath = firstEnv(names)
+		return nil
+	})
+}
+
+// WithBaseDir creates an Option that looks for configuration files in dir instead of the directory
+// of the running executable when neither files nor directories are set. An empty dir disables it.
+func WithBaseDir[T any](dir string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.baseDir = dir
+		return nil
+	})
+}
+
+// WithYAMLRoundTrip creates an Option that makes writes update existing YAML files instead of
+// replacing them with the encoded configuration. Only values differing from the configuration
+// are changed, so comments, key order, anchors, aliases and << merge keys survive: values
+// inherited through an alias or a merge key are overridden next to it rather than expanded.
+// Unknown keys of structs are kept, entries deleted from map fields are removed. New files and other formats are written as usual.
+func WithYAMLRoundTrip[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.yamlRoundTrip = true
+		return nil
+	})
+}
+
+// WithContinueOnError creates an Option that makes loading skip remote sources and files that fail
+// to be read or decoded instead of stopping at the first one. Skipped sources are reported through
+// the logger, the others are applied, and initialization returns the errors of the skipped sources
+// joined after the remaining options have run. Reloads swap in the successfully loaded configuration
+// and return the errors the same way.
+func WithContinueOnError[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.continueOnError = true
+		return nil
+	})
+}
+
+// WithConfineTo creates an Option that makes initialization fail with ErrPathNotConfined when a resolved
+// configuration file doesn't lie inside dir, e.g. because FilePathEnvName comes from untrusted input.
+// Overlays, drop-in files matched by WithGlob, write targets and their backups are checked as well.
+// Paths are compared after cleaning and resolving symlinks, and are checked before files are opened or written.
+func WithConfineTo[T any](dir string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.confineTo = dir
+		return nil
+	})
+}
+
+// WithFileMode creates an Option that sets the permission of configuration files created by confix,
+// both missing files set by FilePathEnvName and targets of writing options, instead of DefaultFileMode.
+// Existing files keep their permissions.
+func WithFileMode[T any](mode os.FileMode) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.fileMode = mode.Perm()
+		return nil
+	})
+}
+
+// WithDurableWrites creates an Option that makes writes survive a crash or power loss:
+// the temp file is synced to disk before it is renamed into place and the directory
+// is synced after the rename. It costs additional IO on every write.
+func WithDurableWrites[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.durable = true
+		return nil
+	})
+}
+
+// WithFormatSniffing creates an Option that detects the format of configuration files whose extension
+// has no registered format, e.g. "app.conf", and of the standard input without a format set with WithStdin.
+// JSON is tried first when the contents start with '{' or '[', then TOML and then YAML; the first format
+// decoding the contents into the configuration type is used. Files of undetected formats fail with
+// ErrUnsupportedExtension. Such files can't be written back unless their extension has a registered format.
+func WithFormatSniffing[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.sniffFormat = true
+		return nil
+	})
+}
+
+// WithSubtree creates an Option that decodes only the part of configuration files under the dotted
+// key path, e.g. "payments" or "services.payments", so that services can share one file.
+// Files without the key fail to load. Writes replace only the subtree of an existing file,
+// keeping its other keys but not its comments. JSON, YAML and TOML files are supported.
+func WithSubtree[T any](keyPath string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		if keyPath == "" || slices.Contains(strings.Split(keyPath, "."), "") {
+			return fmt.Errorf("invalid key path %q", keyPath)
+		}
+		c.subtree = keyPath
+		return nil
+	})
+}
+
+// WithTempDir creates an Option that creates the temp files of atomic writes in dir instead of
+// the directory of the target file, e.g. when that directory is not writable for new files.
+// dir must be on the same filesystem as the targets, otherwise renaming the temp file fails.
+func WithTempDir[T any](dir string) Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.tempDir = dir
+		return nil
+	})
+}
+
+// WithIgnoreUnsupported creates an Option that skips overlay files with unsupported extensions,
+// e.g. an unrelated "config.local.bak", with a logged note instead of failing initialization.
+// Files set by FilePathEnvName still fail with ErrUnsupportedExtension to catch mistakes;
+// discovered files and WithGlob matches only ever have supported extensions.
+func WithIgnoreUnsupported[T any]() Option[T] {
+	return beforeOptionFunc[T](func(c *config[T]) error {
+		c.ignoreUnsupported = true
+		return nil
 	})
 }
@@ -1,9 +1,19 @@
 package confix
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,6 +37,53 @@ func TestWithValidationAfterInitializing(t *testing.T) {
 	assert.Equal(t, after, *cfg.cfg)
 }
 
+func TestWithValidations(t *testing.T) {
+	errPort := errors.New("port is required")
+	errHost := errors.New("host is required")
+	calls := 0
+	cfg := &config[testConfig]{cfg: &testConfig{}}
+
+	opt := WithValidations(
+		func(*testConfig) error { calls++; return errPort },
+		func(*testConfig) error { calls++; return nil },
+		func(*testConfig) error { calls++; return errHost },
+	)
+	err := opt.apply(cfg)
+	assert.ErrorIs(t, err, errPort)
+	assert.ErrorIs(t, err, errHost)
+	assert.Equal(t, 3, calls)
+
+	assert.NoError(t, WithValidations[testConfig]().apply(cfg))
+	assert.NoError(t, WithValidations(func(*testConfig) error { return nil }).apply(cfg))
+}
+
+func TestValidate(t *testing.T) {
+	errEmpty := errors.New("a is empty")
+	errLong := errors.New("a is too long")
+	notEmpty := func(cfg *testConfig) error {
+		if cfg.A == "" {
+			return errEmpty
+		}
+		return nil
+	}
+	short := func(cfg *testConfig) error {
+		if len(cfg.A) > 3 {
+			return errLong
+		}
+		return nil
+	}
+
+	assert.NoError(t, Validate(&testConfig{A: "abc"}, notEmpty, short))
+	assert.NoError(t, Validate(&testConfig{}))
+	assert.ErrorIs(t, Validate(&testConfig{}, notEmpty, short), errEmpty)
+
+	t.Setenv(FilePathEnvName, path.Join(t.TempDir(), "config.json"))
+	err := Validate(&testConfig{A: "abcd"}, notEmpty, short)
+	assert.ErrorIs(t, err, errLong)
+	assert.NotErrorIs(t, err, errEmpty)
+	assert.NoFileExists(t, os.Getenv(FilePathEnvName))
+}
+
 func TestWithWritingConfigToFile(t *testing.T) {
 	fpath := path.Join(t.TempDir(), "config.yaml")
 	assert.NoFileExists(t, fpath)
@@ -43,3 +100,900 @@ func TestWithWritingConfigToFile(t *testing.T) {
 		require.NoError(t, os.Remove(fpath))
 	}
 }
+
+func TestWithWritingConfigToFiles(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := path.Join(dir, "out", "config.json")
+	yamlPath := path.Join(dir, "config.yaml")
+	badPath := path.Join(dir, "config.xml")
+	require.NoError(t, os.MkdirAll(path.Dir(jsonPath), 0o700))
+
+	cfg := config[testConfig]{
+		cfg: &testConfig{A: "a"},
+	}
+	err := WithWritingConfigToFiles[testConfig](jsonPath, yamlPath, badPath).apply(&cfg)
+	assert.ErrorIs(t, err, ErrUnsupportedExtension)
+	assert.NoFileExists(t, badPath)
+
+	data, err := os.ReadFile(jsonPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": "a"}`, string(data))
+	data, err = os.ReadFile(yamlPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "a: a")
+
+	assert.NoError(t, WithWritingConfigToFiles[testConfig]().apply(&cfg))
+}
+
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Logf(format string, args ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestWithLogger(t *testing.T) {
+	l := &testLogger{}
+	cfg := config[testConfig]{
+		cfg: new(testConfig),
+	}
+	opt := WithLogger[testConfig](l)
+	assert.IsType(t, beforeOptionFunc[testConfig](nil), opt)
+	require.NoError(t, opt.apply(&cfg))
+
+	cfg.logf("message %d", 1)
+	assert.Equal(t, []string{"message 1"}, l.messages)
+}
+
+func TestWithRequireFile(t *testing.T) {
+	t.Run("negative: no files", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, t.TempDir())
+
+		err := New(new(testConfig), WithRequireFile[testConfig]())
+		assert.ErrorIs(t, err, ErrNoConfigFound)
+	})
+	t.Run("positive: file found", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithRequireFile[testConfig]()))
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("positive: lenient by default", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, t.TempDir())
+
+		assert.NoError(t, New(new(testConfig)))
+	})
+}
+
+func TestWithEmbeddedDefaults(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/config.yaml": {Data: []byte("a: embedded\n")},
+	}
+	t.Run("positive", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, t.TempDir())
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithEmbeddedDefaults[testConfig](fsys, "defaults/config.yaml")))
+		assert.Equal(t, "embedded", cfg.A)
+	})
+	t.Run("positive: files override", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "file"}`), 0o600))
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithEmbeddedDefaults[testConfig](fsys, "defaults/config.yaml")))
+		assert.Equal(t, "file", cfg.A)
+	})
+	t.Run("negative: missing file", func(t *testing.T) {
+		c := &config[testConfig]{cfg: new(testConfig)}
+		err := WithEmbeddedDefaults[testConfig](fsys, "missing.yaml").apply(c)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
+
+func TestWithFormatPriority(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte(`a: yaml`), 0o600))
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.toml"), []byte(`a = "toml"`), 0o600))
+
+	t.Run("positive: yaml wins", func(t *testing.T) {
+		cfg := new(testConfig)
+		res, err := NewWithResult(cfg,
+			WithFormatPriority[testConfig]([]string{"toml", ".json", "yaml"}),
+			WithMergeAllFound[testConfig](),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "yaml", cfg.A)
+		assert.Equal(t, []string{
+			path.Join(dir, "config.toml"),
+			path.Join(dir, "config.json"),
+			path.Join(dir, "config.yaml"),
+		}, res.LoadedPaths)
+	})
+	t.Run("positive: json wins", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithFormatPriority[testConfig]([]string{".yaml", ".json"})))
+		assert.Equal(t, "json", cfg.A)
+	})
+}
+
+func TestWithMergeAllFound(t *testing.T) {
+	type mergeConfig struct {
+		A string `json:"a" yaml:"a"`
+		B string `json:"b" yaml:"b"`
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json", "b": "json"}`), 0o600))
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte(`a: yaml`), 0o600))
+
+	t.Run("positive: highest priority only by default", func(t *testing.T) {
+		cfg := new(mergeConfig)
+		res, err := NewWithResult(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, mergeConfig{A: "yaml"}, *cfg)
+		assert.Equal(t, []string{path.Join(dir, "config.yaml")}, res.LoadedPaths)
+	})
+	t.Run("positive: merge", func(t *testing.T) {
+		cfg := new(mergeConfig)
+		res, err := NewWithResult(cfg, WithMergeAllFound[mergeConfig]())
+		require.NoError(t, err)
+		assert.Equal(t, mergeConfig{A: "yaml", B: "json"}, *cfg)
+		assert.Equal(t, []string{path.Join(dir, "config.json"), path.Join(dir, "config.yaml")}, res.LoadedPaths)
+	})
+	t.Run("positive: empty files are passed over", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.ini"), nil, 0o600))
+		cfg := new(mergeConfig)
+		res, err := NewWithResult(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, mergeConfig{A: "yaml"}, *cfg)
+		assert.Equal(t, []string{path.Join(dir, "config.ini")}, res.SkippedEmpty)
+	})
+}
+
+func TestWithOverlay(t *testing.T) {
+	type overlayConfig struct {
+		A string `json:"a" yaml:"a"`
+		B string `json:"b" yaml:"b"`
+		C string `json:"c" yaml:"c"`
+	}
+	dir := t.TempDir()
+	base := path.Join(dir, "config.yaml")
+	prod := path.Join(dir, "config.prod.yaml")
+	local := path.Join(dir, "config.local.json")
+	missing := path.Join(dir, "config.missing.yaml")
+	require.NoError(t, os.WriteFile(base, []byte("a: base\nb: base\nc: base\n"), 0o600))
+	require.NoError(t, os.WriteFile(prod, []byte("b: prod\nc: prod\n"), 0o600))
+	require.NoError(t, os.WriteFile(local, []byte(`{"c": "local"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, base)
+
+	cfg := new(overlayConfig)
+	res, err := NewWithResult(cfg, WithOverlay[overlayConfig](prod, missing, local))
+	require.NoError(t, err)
+	assert.Equal(t, overlayConfig{A: "base", B: "prod", C: "local"}, *cfg)
+	assert.Equal(t, []string{base, prod, local}, res.LoadedPaths)
+	assert.Equal(t, []string{missing}, res.SkippedMissing)
+}
+
+func TestWithIgnoreUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	base := path.Join(dir, "config.json")
+	local := path.Join(dir, "config.local.json")
+	backup := path.Join(dir, "config.local.bak")
+	require.NoError(t, os.WriteFile(base, []byte(`{"a": "base"}`), 0o600))
+	require.NoError(t, os.WriteFile(local, []byte(`{"a": "local"}`), 0o600))
+	require.NoError(t, os.WriteFile(backup, []byte(`{"a": "backup"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, base)
+
+	cfg := new(testConfig)
+	assert.ErrorIs(t, New(cfg, WithOverlay[testConfig](local, backup)), ErrUnsupportedExtension)
+
+	l := &testLogger{}
+	cfg = new(testConfig)
+	require.NoError(t, New(cfg,
+		WithOverlay[testConfig](backup, local),
+		WithIgnoreUnsupported[testConfig](),
+		WithLogger[testConfig](l)))
+	assert.Equal(t, "local", cfg.A)
+	if assert.Len(t, l.messages, 1) {
+		assert.Contains(t, l.messages[0], backup)
+	}
+
+	// explicitly requested files still fail
+	t.Setenv(FilePathEnvName, backup)
+	assert.ErrorIs(t, New(new(testConfig), WithIgnoreUnsupported[testConfig]()), ErrUnsupportedExtension)
+}
+
+func TestWithRequireOverlays(t *testing.T) {
+	dir := t.TempDir()
+	base := path.Join(dir, "config.json")
+	prod := path.Join(dir, "config.prod.json")
+	missing1 := path.Join(dir, "config.missing.yaml")
+	missing2 := path.Join(dir, "config.local.json")
+	require.NoError(t, os.WriteFile(base, []byte(`{"a": "base"}`), 0o600))
+	require.NoError(t, os.WriteFile(prod, []byte(`{"a": "prod"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, base)
+
+	cfg := new(testConfig)
+	err := New(cfg, WithOverlay[testConfig](missing1, prod, missing2), WithRequireOverlays[testConfig]())
+	var missingErr *MissingFilesError
+	if assert.ErrorAs(t, err, &missingErr) {
+		assert.Equal(t, []string{missing1, missing2}, missingErr.Paths)
+	}
+	assert.ErrorContains(t, err, missing1+", "+missing2)
+
+	cfg = new(testConfig)
+	require.NoError(t, New(cfg, WithOverlay[testConfig](prod), WithRequireOverlays[testConfig]()))
+	assert.Equal(t, "prod", cfg.A)
+}
+
+func TestWithErrorOnEmpty(t *testing.T) {
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+
+	t.Run("negative: explicit file", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, nil, 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		require.NoError(t, New(new(testConfig)))
+		err := New(new(testConfig), WithErrorOnEmpty[testConfig]())
+		assert.ErrorIs(t, err, ErrEmptyConfigFile)
+		assert.ErrorContains(t, err, fpath)
+	})
+	t.Run("negative: reload", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "json"}`), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		h, err := Open(new(testConfig), WithErrorOnEmpty[testConfig]())
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(fpath, nil, 0o600))
+		assert.ErrorIs(t, h.Reload(), ErrEmptyConfigFile)
+		assert.Equal(t, "json", h.c.cfg.A)
+	})
+	t.Run("negative: overlay", func(t *testing.T) {
+		dir := t.TempDir()
+		base := path.Join(dir, "config.json")
+		overlay := path.Join(dir, "config.local.json")
+		require.NoError(t, os.WriteFile(base, []byte(`{"a": "base"}`), 0o600))
+		require.NoError(t, os.WriteFile(overlay, nil, 0o600))
+		t.Setenv(FilePathEnvName, base)
+
+		err := New(new(testConfig), WithOverlay[testConfig](overlay), WithErrorOnEmpty[testConfig]())
+		assert.ErrorIs(t, err, ErrEmptyConfigFile)
+	})
+	t.Run("positive: discovered file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), nil, 0o600))
+		t.Setenv(DirEnvName, dir)
+
+		cfg := new(testConfig)
+		res, err := NewWithResult(cfg, WithErrorOnEmpty[testConfig]())
+		require.NoError(t, err)
+		assert.Equal(t, "json", cfg.A)
+		assert.Equal(t, []string{path.Join(dir, "config.yaml")}, res.SkippedEmpty)
+	})
+}
+
+func TestWithSkipUnchanged(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	l := &testLogger{}
+	c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+	require.NoError(t, WithLogger[testConfig](l).apply(c))
+	require.NoError(t, WithSkipUnchanged[testConfig]().apply(c))
+
+	require.NoError(t, c.writeToFile(fpath))
+	assert.Empty(t, l.messages)
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(fpath, past, past))
+
+	require.NoError(t, c.writeToFile(fpath))
+	fi, err := os.Stat(fpath)
+	require.NoError(t, err)
+	assert.Equal(t, past, fi.ModTime())
+	if assert.Len(t, l.messages, 1) {
+		assert.Contains(t, l.messages[0], fpath)
+	}
+
+	c.cfg.A = "b"
+	require.NoError(t, c.writeToFile(fpath))
+	fi, err = os.Stat(fpath)
+	require.NoError(t, err)
+	assert.NotEqual(t, past, fi.ModTime())
+}
+
+func TestWithBackupOnWrite(t *testing.T) {
+	t.Run("DefaultSuffix", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+		require.NoError(t, WithBackupOnWrite[testConfig]("").apply(c))
+
+		require.NoError(t, c.writeToFile(fpath))
+		assert.NoFileExists(t, fpath+".bak")
+
+		old, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+
+		c.cfg.A = "b"
+		require.NoError(t, c.writeToFile(fpath))
+
+		backup, err := os.ReadFile(fpath + ".bak")
+		require.NoError(t, err)
+		assert.Equal(t, old, backup)
+
+		current, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Contains(t, string(current), `"b"`)
+	})
+
+	t.Run("CustomSuffix", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a":"old"}`), 0o640))
+		c := &config[testConfig]{cfg: &testConfig{A: "new"}}
+		require.NoError(t, WithBackupOnWrite[testConfig](".orig").apply(c))
+
+		require.NoError(t, c.writeToFile(fpath))
+
+		backup, err := os.ReadFile(fpath + ".orig")
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":"old"}`, string(backup))
+	})
+}
+
+func TestWithDryRun(t *testing.T) {
+	t.Run("Sync", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "file"}`), 0o600))
+
+		l := &testLogger{}
+		res, err := NewWithResult(&testConfig{},
+			WithLogger[testConfig](l),
+			WithDryRun[testConfig](),
+			WithValidation(func(cfg *testConfig) error {
+				cfg.A = "changed"
+				return nil
+			}),
+			WithSyncingConfigToFiles[testConfig](),
+		)
+		require.NoError(t, err)
+
+		if assert.Len(t, res.PlannedWrites, 1) {
+			assert.Equal(t, fpath, res.PlannedWrites[0].Path)
+			assert.JSONEq(t, `{"a": "changed"}`, string(res.PlannedWrites[0].Data))
+		}
+		if assert.Len(t, l.messages, 1) {
+			assert.Contains(t, l.messages[0], fpath)
+		}
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a": "file"}`, string(data))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("MissingSingleFile", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		t.Setenv(FilePathEnvName, fpath)
+
+		res, err := NewWithResult(&testConfig{A: "default"}, WithDryRun[testConfig]())
+		require.NoError(t, err)
+
+		assert.NoFileExists(t, fpath)
+		if assert.Len(t, res.PlannedWrites, 1) {
+			assert.Equal(t, fpath, res.PlannedWrites[0].Path)
+		}
+	})
+}
+
+func TestWithEncoderOptions(t *testing.T) {
+	type nested struct {
+		Inner struct {
+			B string `json:"b" yaml:"b" toml:"b"`
+		} `json:"inner" yaml:"inner" toml:"inner"`
+	}
+	cfg := &nested{}
+	cfg.Inner.B = "x"
+
+	for _, tc := range []struct {
+		name     string
+		ext      string
+		opts     EncoderOptions
+		expected string
+	}{
+		{"JSONDefault", ".json", EncoderOptions{}, "{\n  \"inner\": {\n    \"b\": \"x\"\n  }\n}\n"},
+		{"JSONTabs", ".json", EncoderOptions{JSONIndent: "\t"}, "{\n\t\"inner\": {\n\t\t\"b\": \"x\"\n\t}\n}\n"},
+		{"YAMLDefault", ".yaml", EncoderOptions{}, "inner:\n  b: x\n"},
+		{"YAMLIndent", ".yaml", EncoderOptions{YAMLIndent: 4}, "inner:\n    b: x\n"},
+		{"TOMLDefault", ".toml", EncoderOptions{}, "[inner]\n  b = \"x\"\n"},
+		{"TOMLTabs", ".toml", EncoderOptions{TOMLIndent: "\t"}, "[inner]\n\tb = \"x\"\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &config[nested]{cfg: cfg}
+			require.NoError(t, WithEncoderOptions[nested](tc.opts).apply(c))
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, c.encode(buf, tc.ext))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestWithNoCreate(t *testing.T) {
+	t.Run("Skipped", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := &testConfig{A: "default"}
+		res, err := NewWithResult(cfg, WithNoCreate[testConfig]())
+		require.NoError(t, err)
+
+		assert.NoFileExists(t, fpath)
+		assert.Equal(t, "default", cfg.A)
+		assert.Equal(t, []string{fpath}, res.SkippedMissing)
+	})
+
+	t.Run("Existing", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		t.Setenv(FilePathEnvName, fpath)
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "file"}`), 0o600))
+
+		cfg := &testConfig{A: "default"}
+		require.NoError(t, New(cfg, WithNoCreate[testConfig]()))
+		assert.Equal(t, "file", cfg.A)
+	})
+
+	t.Run("RequireFile", func(t *testing.T) {
+		t.Setenv(FilePathEnvName, path.Join(t.TempDir(), "config.json"))
+
+		err := New(&testConfig{}, WithNoCreate[testConfig](), WithRequireFile[testConfig]())
+		assert.ErrorIs(t, err, ErrNoConfigFound)
+	})
+}
+
+func TestWithXDGSearch(t *testing.T) {
+	t.Setenv(FilePathEnvName, "")
+	t.Setenv(DirEnvName, "")
+
+	t.Run("XDGConfigHome", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		require.NoError(t, os.Mkdir(path.Join(dir, "myapp"), 0o700))
+		fpath := path.Join(dir, "myapp", "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "xdg"}`), 0o600))
+
+		cfg := &testConfig{}
+		res, err := NewWithResult(cfg, WithXDGSearch[testConfig]("myapp"))
+		require.NoError(t, err)
+		assert.Equal(t, "xdg", cfg.A)
+		assert.Equal(t, []string{fpath}, res.LoadedPaths)
+	})
+
+	t.Run("Home", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("HOME", home)
+		require.NoError(t, os.MkdirAll(path.Join(home, ".config", "myapp"), 0o700))
+		fpath := path.Join(home, ".config", "myapp", "config.yaml")
+		require.NoError(t, os.WriteFile(fpath, []byte("a: home\n"), 0o600))
+
+		cfg := &testConfig{}
+		require.NoError(t, New(cfg, WithXDGSearch[testConfig]("myapp")))
+		assert.Equal(t, "home", cfg.A)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		require.NoError(t, os.Mkdir(path.Join(dir, "myapp"), 0o700))
+		require.NoError(t, os.WriteFile(path.Join(dir, "myapp", "config.json"), []byte(`{"a": "xdg"}`), 0o600))
+
+		cfg := &testConfig{}
+		require.NoError(t, New(cfg))
+		assert.Empty(t, cfg.A)
+	})
+}
+
+func TestWithStrictDecoding(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data string
+		key  string
+	}{
+		"config.json": {`{"a": "value", "typo": 1}`, "typo"},
+		"config.yaml": {"a: value\ntypo: 1\n", "typo"},
+		"config.toml": {"a = \"value\"\ntypo = 1\n", "typo"},
+		"config.ini":  {"a = value\ntypo = 1\n", "typo"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.Unsetenv(FilePathEnvName))
+			t.Setenv(DirEnvName, dir)
+			require.NoError(t, os.WriteFile(path.Join(dir, name), []byte(tc.data), 0o600))
+
+			cfg := &testConfig{}
+			require.NoError(t, New(cfg))
+			assert.Equal(t, "value", cfg.A)
+
+			err := New(&testConfig{}, WithStrictDecoding[testConfig]())
+			assert.ErrorContains(t, err, tc.key)
+		})
+	}
+}
+
+func TestTOMLUndecodedKeysWarning(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	fpath := path.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(fpath, []byte("a = \"value\"\ntypo = 1\n\n[stale]\nkey = 2\n"), 0o600))
+
+	l := &testLogger{}
+	cfg := &testConfig{}
+	require.NoError(t, New(cfg, WithLogger[testConfig](l)))
+	assert.Equal(t, "value", cfg.A)
+	if assert.Len(t, l.messages, 1) {
+		assert.Contains(t, l.messages[0], fpath)
+		assert.Contains(t, l.messages[0], "typo")
+		assert.Contains(t, l.messages[0], "stale.key")
+	}
+}
+
+func TestWithOnLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	fpath := path.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "file"}`), 0o600))
+
+	var calls []string
+	cfg := &testConfig{}
+	err := New(cfg,
+		WithOnLoad(func(cfg *testConfig) error {
+			calls = append(calls, "onload:"+cfg.A)
+			cfg.A += "-derived"
+			return nil
+		}),
+		WithValidation(func(cfg *testConfig) error {
+			calls = append(calls, "validation:"+cfg.A)
+			return nil
+		}),
+		WithSyncingConfigToFiles[testConfig](),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"onload:file", "validation:file-derived"}, calls)
+
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": "file-derived"}`, string(data))
+
+	assert.ErrorIs(t, New(&testConfig{}, WithOnLoad(func(*testConfig) error { return errUnparsable })), errUnparsable)
+}
+
+func TestOptionPhases(t *testing.T) {
+	type phaseConfig struct {
+		A string `config:"a,required" json:"a"`
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	t.Setenv("PHASE_A", "env")
+	fpath := path.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{}`), 0o600))
+
+	var calls []string
+	cfg := &phaseConfig{}
+	err := New(cfg,
+		WithSyncingConfigToFiles[phaseConfig](),
+		WithValidation(func(cfg *phaseConfig) error {
+			calls = append(calls, "validate:"+cfg.A)
+			return nil
+		}),
+		WithOnLoad(func(cfg *phaseConfig) error {
+			calls = append(calls, "onload:"+cfg.A)
+			return nil
+		}),
+		WithEnvOverrides[phaseConfig]("PHASE"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"onload:", "validate:env"}, calls)
+
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": "env"}`, string(data))
+}
+
+func TestWithSyncingConfigToFilesLoadedOnly(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		jsonPath := path.Join(dir, "config.json")
+		yamlPath := path.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(jsonPath, []byte(`{"a": "json"}`), 0o600))
+		require.NoError(t, os.WriteFile(yamlPath, []byte("a: yaml\n"), 0o600))
+		return jsonPath, yamlPath
+	}
+	update := WithOnLoad(func(cfg *testConfig) error {
+		cfg.A = "synced"
+		return nil
+	})
+
+	t.Run("Loaded", func(t *testing.T) {
+		jsonPath, yamlPath := setup(t)
+		require.NoError(t, New(&testConfig{}, update, WithSyncingConfigToFiles[testConfig]()))
+
+		data, err := os.ReadFile(yamlPath)
+		require.NoError(t, err)
+		assert.Equal(t, "a: synced\n", string(data))
+
+		data, err = os.ReadFile(jsonPath)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a": "json"}`, string(data))
+	})
+	t.Run("Merged", func(t *testing.T) {
+		jsonPath, yamlPath := setup(t)
+		require.NoError(t, New(&testConfig{}, update, WithMergeAllFound[testConfig](), WithSyncingConfigToFiles[testConfig]()))
+
+		data, err := os.ReadFile(yamlPath)
+		require.NoError(t, err)
+		assert.Equal(t, "a: synced\n", string(data))
+
+		data, err = os.ReadFile(jsonPath)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"a": "synced"}`, string(data))
+	})
+}
+
+func TestWithSyncPrimaryOnly(t *testing.T) {
+	t.Run("Existing", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		jsonPath := path.Join(dir, "config.json")
+		yamlPath := path.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(jsonPath, []byte(`{"a": "json"}`), 0o600))
+		require.NoError(t, os.WriteFile(yamlPath, []byte("a: yaml\n"), 0o600))
+
+		err := New(&testConfig{},
+			WithSyncPrimaryOnly[testConfig](),
+			WithOnLoad(func(cfg *testConfig) error {
+				cfg.A = "synced"
+				return nil
+			}),
+			WithSyncingConfigToFiles[testConfig](),
+		)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(yamlPath)
+		require.NoError(t, err)
+		assert.Equal(t, "a: synced\n", string(data))
+
+		data, err = os.ReadFile(jsonPath)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a": "json"}`, string(data))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+
+		err := New(&testConfig{A: "default"},
+			WithSyncPrimaryOnly[testConfig](),
+			WithFormatPriority[testConfig]([]string{"ini", "yaml", "toml", "json"}),
+			WithSyncingConfigToFiles[testConfig](),
+		)
+		require.NoError(t, err)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "config.json", entries[0].Name())
+		}
+	})
+}
+
+func TestWithWriteConcurrency(t *testing.T) {
+	var current, peak atomic.Int32
+	require.NoError(t, RegisterFormat(".concurrency", decodeKV, func(w io.Writer, v any) error {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		return encodeKV(w, v)
+	}))
+
+	dir := t.TempDir()
+	paths := make([]string, 8)
+	for i := range paths {
+		paths[i] = path.Join(dir, fmt.Sprintf("config%d.concurrency", i))
+	}
+
+	for _, n := range []int{-1, 1, 3} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			peak.Store(0)
+			c := &config[testConfig]{cfg: &testConfig{A: "a"}}
+			require.NoError(t, WithWriteConcurrency[testConfig](n).apply(c))
+
+			require.NoError(t, c.writeToPaths(paths))
+			assert.Equal(t, int32(max(n, 1)), peak.Load())
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		missing := path.Join(dir, "missing")
+		c := &config[testConfig]{cfg: &testConfig{}}
+		require.NoError(t, WithWriteConcurrency[testConfig](1).apply(c))
+
+		err := c.writeToPaths([]string{
+			path.Join(missing, "a.json"),
+			path.Join(missing, "b.json"),
+		})
+		var joined interface{ Unwrap() []error }
+		if assert.ErrorAs(t, err, &joined) {
+			assert.Len(t, joined.Unwrap(), 2)
+		}
+	})
+}
+
+func TestWithGlob(t *testing.T) {
+	type dropIn struct {
+		A string `json:"a" yaml:"a"`
+		B string `json:"b" yaml:"b"`
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte("a: main\nb: main\n"), 0o600))
+
+	confd := path.Join(dir, "conf.d")
+	require.NoError(t, os.Mkdir(confd, 0o700))
+	require.NoError(t, os.Mkdir(path.Join(confd, "00-dir.yaml"), 0o700))
+	require.NoError(t, os.WriteFile(path.Join(confd, "20-local.yaml"), []byte("b: local\n"), 0o600))
+	require.NoError(t, os.WriteFile(path.Join(confd, "10-base.yaml"), []byte("a: base\nb: base\n"), 0o600))
+	require.NoError(t, os.WriteFile(path.Join(confd, "15-notes.txt"), []byte("not a config"), 0o600))
+	require.NoError(t, os.WriteFile(path.Join(confd, "30-extra.json"), []byte(`{"a": "json"}`), 0o600))
+
+	cfg := &dropIn{}
+	res, err := NewWithResult(cfg, WithGlob[dropIn](path.Join(confd, "*.yaml")))
+	require.NoError(t, err)
+	assert.Equal(t, &dropIn{A: "base", B: "local"}, cfg)
+	assert.Equal(t, []string{
+		path.Join(dir, "config.yaml"),
+		path.Join(confd, "10-base.yaml"),
+		path.Join(confd, "20-local.yaml"),
+	}, res.LoadedPaths)
+
+	cfg = &dropIn{}
+	require.NoError(t, New(cfg, WithGlob[dropIn](path.Join(confd, "*"))))
+	assert.Equal(t, &dropIn{A: "json", B: "local"}, cfg)
+
+	assert.ErrorIs(t, New(&dropIn{}, WithGlob[dropIn]("[")), filepath.ErrBadPattern)
+}
+
+func TestWithContinueOnError(t *testing.T) {
+	type layeredConfig struct {
+		A string `json:"a"`
+		B string `json:"b"`
+	}
+
+	t.Run("positive: merged files", func(t *testing.T) {
+		dir := t.TempDir()
+		files := map[string]string{
+			"a.json":      `{"a": "a"}`,
+			"broken.json": `{"a": "broken", "b": `,
+			"b.json":      `{"b": "b"}`,
+		}
+		for name, data := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+		}
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, strings.Join([]string{
+			filepath.Join(dir, "a.json"),
+			filepath.Join(dir, "broken.json"),
+			filepath.Join(dir, "b.json"),
+		}, string(os.PathListSeparator)))
+
+		logger := &testLogger{}
+		validated := false
+		cfg := new(layeredConfig)
+		err := New(cfg,
+			WithContinueOnError[layeredConfig](),
+			WithLogger[layeredConfig](logger),
+			WithValidation(func(*layeredConfig) error {
+				validated = true
+				return nil
+			}))
+		assert.ErrorContains(t, err, filepath.Join(dir, "broken.json"))
+		assert.Equal(t, &layeredConfig{A: "a", B: "b"}, cfg)
+		assert.True(t, validated)
+		if assert.Len(t, logger.messages, 1) {
+			assert.Contains(t, logger.messages[0], "WARNING: skipping config source")
+		}
+
+		// fail fast by default
+		cfg = new(layeredConfig)
+		assert.Error(t, New(cfg))
+		assert.Equal(t, &layeredConfig{A: "a"}, cfg)
+	})
+	t.Run("positive: fallback to lower priority file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("a: [\n"), 0o600))
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+
+		cfg := new(layeredConfig)
+		err := New(cfg, WithContinueOnError[layeredConfig](), WithLogger[layeredConfig](&testLogger{}))
+		assert.ErrorContains(t, err, "config.yaml")
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("positive: partial decode discarded", func(t *testing.T) {
+		type mapConfig struct {
+			A string         `json:"a"`
+			M map[string]int `json:"m"`
+			S []int          `json:"s"`
+		}
+		dir := t.TempDir()
+		// the type error of a is reported after m and s are decoded
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"m": {"x": 1}, "s": [9], "a": 5}`), 0o600))
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+
+		s := make([]int, 1, 2)
+		cfg := &mapConfig{M: map[string]int{"a": 1}, S: s}
+		err := New(cfg, WithContinueOnError[mapConfig](), WithLogger[mapConfig](&testLogger{}))
+		assert.ErrorContains(t, err, "config.json")
+		assert.Equal(t, &mapConfig{M: map[string]int{"a": 1}, S: []int{0}}, cfg)
+		assert.Equal(t, []int{0}, s)
+	})
+	t.Run("positive: reload", func(t *testing.T) {
+		dir := t.TempDir()
+		fpath := filepath.Join(dir, "config.json")
+		overlay := filepath.Join(dir, "overlay.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "first"}`), 0o600))
+		require.NoError(t, os.WriteFile(overlay, []byte(`{"b": "overlay"}`), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(layeredConfig)
+		h, err := Open(cfg,
+			WithContinueOnError[layeredConfig](),
+			WithOverlay[layeredConfig](overlay),
+			WithLogger[layeredConfig](&testLogger{}))
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "second"}`), 0o600))
+		require.NoError(t, os.WriteFile(overlay, []byte(`{"b": `), 0o600))
+		assert.ErrorContains(t, h.Reload(), overlay)
+		assert.Equal(t, &layeredConfig{A: "second", B: "overlay"}, cfg)
+	})
+}
@@ -0,0 +1,106 @@
+package confix
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// redactedValue replaces values of fields tagged with the secret modifier.
+const redactedValue = "***"
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// Redacted returns the JSON encoding of cfg with the values of fields tagged
+// `config:"...,secret"` replaced by "***", so that the configuration can be logged safely.
+// Nested structs, pointers, slices and maps are redacted as well.
+func Redacted[T any](cfg *T) string {
+	data, err := json.Marshal(redact(reflect.ValueOf(cfg)))
+	if err != nil {
+		return fmt.Sprintf("<error while redacting config: %v>", err)
+	}
+	return string(data)
+}
+
+// redact returns a copy of v suitable for JSON encoding in which secret fields are replaced.
+// Structs become maps keyed by their JSON property names. Values implementing json.Marshaler
+// or encoding.TextMarshaler, such as time.Time and netip.Addr, and byte slices are kept as they are
+// and encoded as single values.
+func redact(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	}
+
+	for _, t := range []reflect.Type{jsonMarshalerType, textMarshalerType} {
+		if v.Type().Implements(t) {
+			return v.Interface()
+		}
+		if v.CanAddr() && v.Addr().Type().Implements(t) {
+			return v.Addr().Interface()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := map[string]any{}
+		redactStruct(v, m)
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes()
+		}
+		s := make([]any, v.Len())
+		for i := range s {
+			s[i] = redact(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = redact(iter.Value())
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
+
+// redactStruct stores the redacted exported fields of the struct v in m,
+// flattening embedded structs the way encoding/json does.
+func redactStruct(v reflect.Value, m map[string]any) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			redactStruct(v.Field(i), m)
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		if _, opts := parseConfigTag(f); hasTagOption(opts, "secret") {
+			m[name] = redactedValue
+			continue
+		}
+		m[name] = redact(v.Field(i))
+	}
+}
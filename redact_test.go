@@ -0,0 +1,74 @@
+package confix
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedacted(t *testing.T) {
+	type db struct {
+		User     string `json:"user"`
+		Password string `json:"password" config:"password,secret"`
+	}
+	type embedded struct {
+		Region string
+		Token  string `config:"token,secret"`
+	}
+	type cfg struct {
+		embedded
+		Name     string            `json:"name"`
+		APIKey   string            `json:"api_key" config:"api_key,secret"`
+		DB       db                `json:"db"`
+		Replicas []db              `json:"replicas"`
+		Backup   *db               `json:"backup"`
+		ByName   map[string]db     `json:"by_name"`
+		Labels   map[string]string `json:"labels"`
+		Started  time.Time         `json:"started"`
+		Timeout  time.Duration     `json:"timeout"`
+		Addr     netip.Addr        `json:"addr"`
+		IP       net.IP            `json:"ip"`
+		Data     []byte            `json:"data"`
+		Ignored  string            `json:"-"`
+		internal string
+	}
+
+	c := &cfg{
+		embedded: embedded{Region: "eu", Token: "t0ken"},
+		Name:     "app",
+		APIKey:   "k3y",
+		DB:       db{User: "admin", Password: "pa55"},
+		Replicas: []db{{User: "r1", Password: "pa55"}},
+		ByName:   map[string]db{"main": {User: "m", Password: "pa55"}},
+		Labels:   map[string]string{"env": "prod"},
+		Started:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout:  time.Second,
+		Addr:     netip.MustParseAddr("10.0.0.1"),
+		IP:       net.IPv4(10, 0, 0, 2),
+		Data:     []byte("data"),
+		Ignored:  "ignored",
+		internal: "internal",
+	}
+
+	assert.JSONEq(t, `{
+		"Region": "eu",
+		"Token": "***",
+		"name": "app",
+		"api_key": "***",
+		"db": {"user": "admin", "password": "***"},
+		"replicas": [{"user": "r1", "password": "***"}],
+		"backup": null,
+		"by_name": {"main": {"user": "m", "password": "***"}},
+		"labels": {"env": "prod"},
+		"started": "2024-01-02T03:04:05Z",
+		"timeout": 1000000000,
+		"addr": "10.0.0.1",
+		"ip": "10.0.0.2",
+		"data": "ZGF0YQ=="
+	}`, Redacted(c))
+	assert.Equal(t, "pa55", c.DB.Password)
+	assert.Equal(t, "null", Redacted[cfg](nil))
+}
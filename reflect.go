@@ -0,0 +1,184 @@
+package confix
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configTagName is the struct tag used by confix to name configuration fields.
+const configTagName = "config"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// parseConfigTag returns the key of the struct field taken from the config tag
+// and the list of comma-separated modifiers that follow it.
+// When the tag is missing or has an empty name, the field name is used.
+func parseConfigTag(f reflect.StructField) (string, []string) {
+	tag := f.Tag.Get(configTagName)
+	name, rest, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	if rest == "" {
+		return name, nil
+	}
+	return name, strings.Split(rest, ",")
+}
+
+// hasTagOption reports whether opts returned by parseConfigTag contain the option.
+func hasTagOption(opts []string, option string) bool {
+	for _, o := range opts {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// setFieldFromString parses s according to the kind of v and assigns the result to v.
+// Supported kinds are string, bool, signed and unsigned integers, floats, time.Duration,
+// types with codecs, types implementing encoding.TextUnmarshaler and slices of them
+// given as comma-separated values.
+func setFieldFromString(v reflect.Value, s string) error {
+	if c, ok := lookupTypeCodec(v.Type()); ok {
+		cv, err := c.decode(s)
+		if err != nil {
+			return err
+		}
+		v.Set(cv)
+		return nil
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if s == "" {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+			return nil
+		}
+		parts := strings.Split(s, ",")
+		sl := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setFieldFromString(sl.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(sl)
+	default:
+		return fmt.Errorf("unsupported field type: %s", v.Type())
+	}
+	return nil
+}
+
+// isTextType reports whether values of the type t are single values written and read as text
+// rather than structs to walk: types with codecs and types implementing encoding.TextMarshaler
+// or encoding.TextUnmarshaler, such as time.Time.
+func isTextType(t reflect.Type) bool {
+	if _, ok := lookupTypeCodec(t); ok {
+		return true
+	}
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// walkStrings calls fn for every settable string reachable from v, including strings
+// nested in structs, pointers, slices, arrays and map values, and stores the returned value.
+func walkStrings(v reflect.Value, fn func(string) (string, error)) error {
+	return walkStringPaths(v, "", func(_, s string) (string, error) {
+		return fn(s)
+	})
+}
+
+// walkStringPaths is walkStrings passing fn the path of every string as well, made of field names,
+// [i] for elements and [key] for map values and starting with path, e.g. DB.Hosts[0].
+func walkStringPaths(v reflect.Value, path string, fn func(path, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return walkStringPaths(v.Elem(), path, fn)
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		s, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if err := walkStringPaths(v.Field(i), joinPath(path, t.Field(i).Name), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStringPaths(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			if err := walkStringPaths(e, fmt.Sprintf("%s[%v]", path, k), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package confix
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Config is a long-lived handle to a configuration loaded by Open. Reloads swap the configuration
+// under a mutex; read it with Get while reloads may run, e.g. from OnSignalReload, OnEtcdChange
+// or OnConsulChange, as reading the struct passed to Open directly races with them.
+type Config[T any] struct {
+	c *config[T]
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
+}
+
+// Open initializes and parses config the same way as New and returns a handle
+// that can reload it later.
+func Open[T any](cfg *T, opts ...Option[T]) (*Config[T], error) {
+	c, err := newConfig[T](context.Background(), cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Config[T]{c: c}, nil
+}
+
+// Get returns a copy of the current configuration. It is safe to call concurrently with reloads.
+func (h *Config[T]) Get() T {
+	h.c.mu.RLock()
+	defer h.c.mu.RUnlock()
+	return *h.c.cfg
+}
+
+// Reload resolves the configuration files again and decodes them into the live configuration.
+// Files are decoded into a copy of the current configuration, to which the transform and validation
+// options given to Open are applied again, e.g. WithEnvOverrides and WithValidation; writing options
+// are not. The copy replaces the live configuration only if all of them succeed, or despite skipped
+// files with WithContinueOnError, so a bad edit never corrupts it.
+// It is safe to call Reload repeatedly and from multiple goroutines.
+func (h *Config[T]) Reload() error {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	if err := h.discover(); err != nil {
+		return err
+	}
+	return h.c.reload(context.Background())
+}
+
+// ReloadField re-reads the configuration files like Reload but copies only the value
+// the selector points to into the live configuration, leaving the rest of it untouched.
+// The selector is called with both the live and the freshly loaded configuration
+// and must return a non-nil pointer into the struct it is given, e.g.
+//
+//	confix.ReloadField(h, func(c *Config) *LogConfig { return &c.Log })
+func ReloadField[T, F any](h *Config[T], selector func(*T) *F) error {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	if err := h.discover(); err != nil {
+		return err
+	}
+	fresh, err := h.c.loadCopy(context.Background())
+	if fresh == nil {
+		return err
+	}
+
+	src := selector(fresh)
+	if src == nil {
+		return errors.New("selector returned nil")
+	}
+	h.c.mu.Lock()
+	defer h.c.mu.Unlock()
+	dst := selector(h.c.cfg)
+	if dst == nil {
+		return errors.New("selector returned nil")
+	}
+	*dst = *src
+	return err
+}
+
+// discover resolves the configuration files again and stores their paths in the handle.
+func (h *Config[T]) discover() error {
+	discovery := &config[T]{
+		settings: h.c.settings,
+		cfg:      new(T),
+		paths:    []string{},
+	}
+	h.c.mu.RLock()
+	*discovery.cfg = *h.c.cfg
+	h.c.mu.RUnlock()
+
+	if err := discovery.getConfigPaths(); err != nil {
+		return err
+	}
+	h.c.paths = discovery.paths
+	h.c.candidates = discovery.candidates
+	h.c.layerPaths = discovery.layerPaths
+	h.c.explicitPaths = discovery.explicitPaths
+	return nil
+}
+
+// OnSignalReload starts a goroutine that calls Reload every time one of the signals is received,
+// SIGHUP when no signals are given. Reload errors are reported through the logger.
+// The returned stop function removes the signal handler and ends the goroutine;
+// it is safe to call more than once.
+func OnSignalReload[T any](h *Config[T], sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				if err := h.Reload(); err != nil {
+					h.c.logf("ERROR: reloading config on signal; err=%v", err)
+				}
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package confix
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Reload(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+
+		cfg := new(testConfig)
+		h, err := Open(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "json", cfg.A)
+
+		// a new file with higher priority is discovered on reload
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte(`a: yaml`), 0o600))
+		require.NoError(t, h.Reload())
+		assert.Equal(t, "yaml", cfg.A)
+
+		require.NoError(t, h.Reload())
+		assert.Equal(t, "yaml", cfg.A)
+	})
+	t.Run("positive: concurrent reloads", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"a": "json"}`), 0o600))
+
+		h, err := Open(new(testConfig))
+		require.NoError(t, err)
+
+		wg := sync.WaitGroup{}
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, h.Reload())
+			}()
+		}
+		wg.Wait()
+	})
+	t.Run("positive: reads during reloads", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "json"}`), 0o600))
+
+		h, err := Open(new(testConfig))
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						assert.Equal(t, "json", h.Get().A)
+					}
+				}
+			}()
+		}
+		for range 20 {
+			require.NoError(t, h.Reload())
+		}
+		close(done)
+		wg.Wait()
+	})
+	t.Run("negative: broken file keeps config", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "json"}`), 0o600))
+
+		cfg := new(testConfig)
+		h, err := Open(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": `), 0o600))
+		assert.Error(t, h.Reload())
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("negative: invalid config keeps config", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "json"}`), 0o600))
+
+		errEmpty := errors.New("a is empty")
+		validated := 0
+		cfg := new(testConfig)
+		h, err := Open(cfg,
+			WithValidation(func(c *testConfig) error {
+				if c.A == "" {
+					return errEmpty
+				}
+				return nil
+			}),
+			WithOnLoad(func(c *testConfig) error {
+				c.A = strings.TrimSpace(c.A)
+				return nil
+			}),
+			WithWritingConfigToFile[testConfig](path.Join(dir, "out.json")),
+			WithValidation(func(*testConfig) error {
+				validated++
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "  "}`), 0o600))
+		assert.ErrorIs(t, h.Reload(), errEmpty)
+		assert.Equal(t, "json", cfg.A)
+
+		// transform options are applied again, writing options are not
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": " reloaded "}`), 0o600))
+		require.NoError(t, os.Remove(path.Join(dir, "out.json")))
+		require.NoError(t, h.Reload())
+		assert.Equal(t, "reloaded", cfg.A)
+		assert.NoFileExists(t, path.Join(dir, "out.json"))
+		assert.Equal(t, 2, validated)
+	})
+	t.Run("negative: open", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, t.TempDir())
+
+		h, err := Open(new(testConfig), WithRequireFile[testConfig]())
+		assert.Nil(t, h)
+		assert.Error(t, err)
+	})
+}
+
+func TestReloadField(t *testing.T) {
+	type logConfig struct {
+		Level string `json:"level"`
+	}
+	type sectionConfig struct {
+		Name string     `json:"name"`
+		Log  logConfig  `json:"log"`
+		DB   *logConfig `json:"db"`
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	fpath := path.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"name": "a", "log": {"level": "info"}}`), 0o600))
+
+	cfg := new(sectionConfig)
+	h, err := Open(cfg)
+	require.NoError(t, err)
+
+	t.Run("positive", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"name": "b", "log": {"level": "debug"}}`), 0o600))
+		require.NoError(t, ReloadField(h, func(c *sectionConfig) *logConfig { return &c.Log }))
+		assert.Equal(t, &sectionConfig{Name: "a", Log: logConfig{Level: "debug"}}, cfg)
+	})
+	t.Run("negative: broken file keeps config", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"log": `), 0o600))
+		assert.Error(t, ReloadField(h, func(c *sectionConfig) *logConfig { return &c.Log }))
+		assert.Equal(t, "debug", cfg.Log.Level)
+	})
+	t.Run("negative: nil selection", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"log": {"level": "warn"}}`), 0o600))
+		assert.Error(t, ReloadField(h, func(c *sectionConfig) *logConfig { return c.DB }))
+		assert.Equal(t, &sectionConfig{Name: "a", Log: logConfig{Level: "debug"}}, cfg)
+	})
+}
+
+func TestOnSignalReload(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, dir)
+	fpath := path.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "before"}`), 0o600))
+
+	h, err := Open(new(testConfig))
+	require.NoError(t, err)
+
+	stop := OnSignalReload(h)
+	defer stop()
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "after"}`), 0o600))
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	if err = p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("sending signals is not supported: %v", err)
+	}
+
+	assert.Eventually(t, func() bool {
+		h.c.mu.RLock()
+		defer h.c.mu.RUnlock()
+		return h.c.cfg.A == "after"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stop()
+	stop()
+}
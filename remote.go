@@ -0,0 +1,81 @@
+package confix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// remoteSource decodes configuration data fetched from outside the filesystem into v
+// with opts, setting the source of opts to the name of the data.
+type remoteSource func(ctx context.Context, v any, opts decodeOptions) error
+
+// contentTypeExtensions maps media types of remote responses to file extensions of decoders.
+var contentTypeExtensions = map[string]string{
+	"application/json":   jsonExt,
+	"application/yaml":   yamlExt,
+	"application/x-yaml": yamlExt,
+	"text/yaml":          yamlExt,
+	"text/x-yaml":        yamlExt,
+	"application/toml":   tomlExt,
+}
+
+// httpSource returns a remoteSource fetching configuration with a GET request to url.
+// The decoder is chosen by the Content-Type of the response, falling back to format.
+func httpSource(client *http.Client, url, format string) remoteSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return func(ctx context.Context, v any, opts decodeOptions) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("error while fetching config from %s: unexpected status %s", url, resp.Status)
+		}
+
+		ext := "." + strings.TrimPrefix(format, ".")
+		if mediaType, _, parseErr := mime.ParseMediaType(resp.Header.Get("Content-Type")); parseErr == nil {
+			if e, ok := contentTypeExtensions[mediaType]; ok {
+				ext = e
+			}
+		}
+
+		opts.source = url
+		return decode(resp.Body, ext, v, opts)
+	}
+}
+
+// keyValueSource returns a remoteSource decoding the value returned by get, the value stored
+// at key in the store named name. The decoder is chosen by format, falling back to the extension of key.
+func keyValueSource(name, key, format string, get func(ctx context.Context) ([]byte, bool, error)) remoteSource {
+	ext := "." + strings.TrimPrefix(format, ".")
+	if format == "" {
+		ext = path.Ext(key)
+	}
+
+	return func(ctx context.Context, v any, opts decodeOptions) error {
+		data, found, err := get(ctx)
+		if err != nil {
+			return fmt.Errorf("error while fetching config from %s key %q: %w", name, key, err)
+		}
+		if !found {
+			return fmt.Errorf("error while fetching config from %s key %q: key not found", name, key)
+		}
+		opts.source = fmt.Sprintf("%s key %q", name, key)
+		return decode(bytes.NewReader(data), ext, v, opts)
+	}
+}
@@ -0,0 +1,73 @@
+package confix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRemoteSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"a": "json"}`))
+		case "/yaml":
+			w.Header().Set("Content-Type", "application/yaml")
+			_, _ = w.Write([]byte(`a: yaml`))
+		case "/toml":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(`a = "toml"`))
+		case "/unknown":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"a": "json", "unknown": 1}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	require.NoError(t, os.Unsetenv(FilePathEnvName))
+	t.Setenv(DirEnvName, t.TempDir())
+
+	t.Run("positive: content type", func(t *testing.T) {
+		for _, p := range []string{"json", "yaml"} {
+			cfg := new(testConfig)
+			require.NoError(t, New(cfg, WithRemoteSource[testConfig](srv.URL+"/"+p, "", nil)))
+			assert.Equal(t, p, cfg.A)
+		}
+	})
+	t.Run("positive: fallback format", func(t *testing.T) {
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithRemoteSource[testConfig](srv.URL+"/toml", "toml", srv.Client())))
+		assert.Equal(t, "toml", cfg.A)
+	})
+	t.Run("positive: local files override", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.yaml"), []byte(`a: local`), 0o600))
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithRemoteSource[testConfig](srv.URL+"/json", "", nil)))
+		assert.Equal(t, "local", cfg.A)
+	})
+	t.Run("negative: status", func(t *testing.T) {
+		err := New(new(testConfig), WithRemoteSource[testConfig](srv.URL+"/missing", "json", nil))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "404")
+		}
+	})
+	t.Run("negative: strict decoding", func(t *testing.T) {
+		err := New(new(testConfig), WithRemoteSource[testConfig](srv.URL+"/unknown", "", nil), WithStrictDecoding[testConfig]())
+		assert.ErrorContains(t, err, "unknown")
+	})
+	t.Run("negative: unknown format", func(t *testing.T) {
+		err := New(new(testConfig), WithRemoteSource[testConfig](srv.URL+"/toml", "", nil))
+		assert.Error(t, err)
+	})
+}
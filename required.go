@@ -0,0 +1,44 @@
+package confix
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrRequiredFieldMissing is returned for every field marked as required that is not set after loading.
+var ErrRequiredFieldMissing = errors.New("required field is not set")
+
+// checkRequired walks the struct v and returns an error for every field tagged with
+// the required modifier, e.g. `config:"name,required"`, that still holds its zero value.
+// Errors are aggregated with errors.Join; field names are config keys joined with dots.
+func checkRequired(v reflect.Value, prefix string) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var resultErr error
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, opts := parseConfigTag(f)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fv := v.Field(i)
+		if hasTagOption(opts, "required") && fv.IsZero() {
+			resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s", ErrRequiredFieldMissing, key))
+			continue
+		}
+		if fv.Kind() == reflect.Struct {
+			resultErr = errors.Join(resultErr, checkRequired(fv, key))
+		}
+	}
+
+	return resultErr
+}
@@ -0,0 +1,52 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type requiredTestConfig struct {
+	Name     string `config:"name,required" json:"name"`
+	Port     int    `config:"port,required" json:"port"`
+	Optional string `config:"optional" json:"optional"`
+	DB       struct {
+		Host string `config:"host,required" json:"host"`
+	} `config:"db" json:"db"`
+}
+
+func TestCheckRequired(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		cfg := &requiredTestConfig{Name: "app", Port: 1}
+		cfg.DB.Host = "localhost"
+		assert.NoError(t, checkRequired(reflect.ValueOf(cfg).Elem(), ""))
+	})
+	t.Run("negative: all missing fields are reported", func(t *testing.T) {
+		cfg := &requiredTestConfig{Port: 1}
+		err := checkRequired(reflect.ValueOf(cfg).Elem(), "")
+		if assert.Error(t, err) {
+			assert.ErrorIs(t, err, ErrRequiredFieldMissing)
+			assert.Contains(t, err.Error(), "name")
+			assert.Contains(t, err.Error(), "db.host")
+			assert.NotContains(t, err.Error(), "port")
+		}
+	})
+}
+
+func TestNew_Required(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"name": "app", "db": {"host": "db"}}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	err := New(new(requiredTestConfig))
+	assert.ErrorIs(t, err, ErrRequiredFieldMissing)
+
+	cfg := &requiredTestConfig{Port: 8080}
+	require.NoError(t, New(cfg))
+	assert.Equal(t, "app", cfg.Name)
+}
@@ -0,0 +1,168 @@
+package confix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaURL is the resource name the user-provided schema is registered under.
+const schemaURL = "confix://schema.json"
+
+// validateJSONSchema marshals v to JSON and validates it against the JSON Schema.
+// Every violation is reported as a separate error, joined with errors.Join,
+// in the form "<instance location>: <message>".
+func validateJSONSchema(schema []byte, v any) error {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(schema)); err != nil {
+		return fmt.Errorf("error while parsing json schema: %w", err)
+	}
+	sch, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return fmt.Errorf("error while compiling json schema: %w", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	err = sch.Validate(doc)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	return schemaViolations(ve)
+}
+
+// schemaViolations collects the leaf causes of the validation error.
+func schemaViolations(ve *jsonschema.ValidationError) error {
+	if len(ve.Causes) == 0 {
+		loc := ve.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		return fmt.Errorf("%s: %s", loc, ve.Message)
+	}
+
+	var resultErr error
+	for _, cause := range ve.Causes {
+		resultErr = errors.Join(resultErr, schemaViolations(cause))
+	}
+	return resultErr
+}
+
+// descriptionTagName is the struct tag holding the description of a field in generated schemas.
+const descriptionTagName = "description"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// GenerateSchema returns a draft-07 JSON Schema describing T. Property names are taken from
+// json tags, falling back to config tags and field names. Fields with the required modifier
+// of the config tag are listed as required and the description tag documents properties.
+func GenerateSchema[T any]() ([]byte, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	schema := typeSchema(t, map[reflect.Type]bool{})
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// typeSchema returns the schema of values of type t. The visiting set guards against recursive types.
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	switch {
+	case t == durationType:
+		return map[string]any{"type": "integer"}
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), visiting)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// byte slices are encoded as base64 strings
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]any{}
+		required := []string{}
+		structSchemaFields(t, visiting, properties, &required)
+
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchemaFields adds the properties of the struct type t to properties,
+// flattening embedded structs the way encoding/json does.
+func structSchemaFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			structSchemaFields(f.Type, visiting, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, ok := schemaPropertyName(f)
+		if !ok {
+			continue
+		}
+
+		prop := typeSchema(f.Type, visiting)
+		if desc := f.Tag.Get(descriptionTagName); desc != "" {
+			prop["description"] = desc
+		}
+		properties[name] = prop
+
+		if _, opts := parseConfigTag(f); hasTagOption(opts, "required") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaPropertyName returns the JSON property name of the field and false if the field is skipped.
+func schemaPropertyName(f reflect.StructField) (string, bool) {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name == "-" {
+		return "", false
+	} else if name != "" {
+		return name, true
+	}
+	name, _ := parseConfigTag(f)
+	return name, name != "-"
+}
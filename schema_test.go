@@ -0,0 +1,116 @@
+package confix
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithJSONSchema(t *testing.T) {
+	type schemaConfig struct {
+		Port  int    `json:"port"`
+		Level string `json:"level"`
+		Name  string `json:"name"`
+	}
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+			"level": {"enum": ["debug", "info", "warn", "error"]},
+			"name": {"type": "string", "pattern": "^[a-z]+$"}
+		}
+	}`)
+
+	t.Run("positive", func(t *testing.T) {
+		c := &config[schemaConfig]{cfg: &schemaConfig{Port: 8080, Level: "info", Name: "app"}}
+		assert.NoError(t, WithJSONSchema[schemaConfig](schema).apply(c))
+	})
+	t.Run("negative: every violation is reported", func(t *testing.T) {
+		c := &config[schemaConfig]{cfg: &schemaConfig{Port: 0, Level: "trace", Name: "App"}}
+		err := WithJSONSchema[schemaConfig](schema).apply(c)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "/port")
+			assert.Contains(t, err.Error(), "/level")
+			assert.Contains(t, err.Error(), "/name")
+		}
+	})
+	t.Run("negative: invalid schema", func(t *testing.T) {
+		c := &config[schemaConfig]{cfg: new(schemaConfig)}
+		assert.Error(t, WithJSONSchema[schemaConfig]([]byte(`{"type": 1}`)).apply(c))
+		assert.Error(t, WithJSONSchema[schemaConfig]([]byte(`{`)).apply(c))
+	})
+}
+
+func TestGenerateSchema(t *testing.T) {
+	type node struct {
+		Children []node `json:"children"`
+	}
+	type embedded struct {
+		Embedded string `json:"embedded"`
+	}
+	type generateConfig struct {
+		embedded
+		Name     string            `json:"name" config:"name,required" description:"Service name"`
+		Port     int               `config:"port,required"`
+		Ratio    float64           `json:"ratio,omitempty"`
+		Debug    bool              `json:"debug"`
+		Timeout  time.Duration     `json:"timeout"`
+		Since    time.Time         `json:"since"`
+		Hosts    []string          `json:"hosts"`
+		Labels   map[string]string `json:"labels"`
+		Secret   []byte            `json:"secret"`
+		Skipped  string            `json:"-"`
+		Internal string            `config:"-"`
+		DB       *struct {
+			Host string `json:"host" config:"host,required"`
+		} `json:"db"`
+		Tree node `json:"tree"`
+	}
+
+	data, err := GenerateSchema[generateConfig]()
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", got["$schema"])
+	assert.Equal(t, "object", got["type"])
+	assert.ElementsMatch(t, []any{"name", "port"}, got["required"])
+
+	props := got["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "description": "Service name"}, props["name"])
+	assert.Equal(t, map[string]any{"type": "integer"}, props["port"])
+	assert.Equal(t, map[string]any{"type": "number"}, props["ratio"])
+	assert.Equal(t, map[string]any{"type": "boolean"}, props["debug"])
+	assert.Equal(t, map[string]any{"type": "integer"}, props["timeout"])
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, props["since"])
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, props["hosts"])
+	assert.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}, props["labels"])
+	assert.Equal(t, map[string]any{"type": "string"}, props["secret"])
+	assert.Equal(t, map[string]any{"type": "string"}, props["embedded"])
+	assert.NotContains(t, props, "Skipped")
+	assert.NotContains(t, props, "Internal")
+	assert.Equal(t, map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"host": map[string]any{"type": "string"}},
+		"required":   []any{"host"},
+	}, props["db"])
+	assert.Equal(t, map[string]any{
+		"type": "object",
+		"properties": map[string]any{"children": map[string]any{
+			"type":  "array",
+			"items": map[string]any{},
+		}},
+	}, props["tree"])
+
+	t.Run("positive: validates generated config", func(t *testing.T) {
+		type validConfig struct {
+			Name string `json:"name" config:"name,required"`
+		}
+		schema, err := GenerateSchema[validConfig]()
+		require.NoError(t, err)
+		assert.NoError(t, validateJSONSchema(schema, &validConfig{Name: "app"}))
+	})
+}
@@ -0,0 +1,52 @@
+package confix
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// secretRefScheme marks string values that refer to secrets resolved by WithSecretResolver.
+const secretRefScheme = "secret://"
+
+// secretRef is a secret reference replaced by WithSecretResolver.
+type secretRef struct {
+	// ref is the reference without the scheme
+	ref string
+	// secret is the value the reference resolved to
+	secret string
+}
+
+// resolveSecrets replaces every string reachable from v that starts with secretRefScheme
+// with the result of resolve called with the reference without the scheme.
+// It returns the replaced references by the paths of the strings.
+func resolveSecrets(v reflect.Value, resolve func(ref string) (string, error)) (map[string]secretRef, error) {
+	refs := map[string]secretRef{}
+	err := walkStringPaths(v, "", func(path, s string) (string, error) {
+		ref, ok := strings.CutPrefix(s, secretRefScheme)
+		if !ok {
+			return s, nil
+		}
+		secret, err := resolve(ref)
+		if err != nil {
+			return "", fmt.Errorf("error while resolving secret %q: %w", ref, err)
+		}
+		refs[path] = secretRef{ref: ref, secret: secret}
+		return secret, nil
+	})
+	return refs, err
+}
+
+// withSecretRefs returns a copy of cfg in which the secrets resolved from refs are replaced
+// by their references again, so that written files never contain them. Values changed
+// since resolution are kept. cfg itself is left untouched.
+func withSecretRefs[T any](cfg *T, refs map[string]secretRef) *T {
+	cp := deepCopy(cfg)
+	_ = walkStringPaths(reflect.ValueOf(cp), "", func(path, s string) (string, error) {
+		if r, ok := refs[path]; ok && r.secret == s {
+			return secretRefScheme + r.ref, nil
+		}
+		return s, nil
+	})
+	return cp
+}
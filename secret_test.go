@@ -0,0 +1,81 @@
+package confix
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSecretResolver(t *testing.T) {
+	type db struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	type secretConfig struct {
+		DB     db                `json:"db"`
+		Tokens []string          `json:"tokens"`
+		Extra  map[string]string `json:"extra"`
+	}
+
+	secrets := map[string]string{
+		"db-password": "pa55",
+		"api/token":   "t0ken",
+	}
+	resolve := func(ref string) (string, error) {
+		s, ok := secrets[ref]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return s, nil
+	}
+
+	t.Run("Resolved", func(t *testing.T) {
+		c := &config[secretConfig]{cfg: &secretConfig{
+			DB:     db{User: "admin", Password: "secret://db-password"},
+			Tokens: []string{"secret://api/token", "plain"},
+			Extra:  map[string]string{"key": "secret://db-password"},
+		}}
+		require.NoError(t, WithSecretResolver[secretConfig](resolve).apply(c))
+
+		assert.Equal(t, &secretConfig{
+			DB:     db{User: "admin", Password: "pa55"},
+			Tokens: []string{"t0ken", "plain"},
+			Extra:  map[string]string{"key": "pa55"},
+		}, c.cfg)
+	})
+
+	t.Run("References written", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		fpath := path.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(fpath,
+			[]byte(`{"db": {"user": "admin", "password": "secret://db-password"}, "tokens": ["secret://api/token"]}`), 0o600))
+
+		cfg := &secretConfig{}
+		require.NoError(t, New(cfg, WithSecretResolver[secretConfig](resolve), WithSyncingConfigToFiles[secretConfig]()))
+		assert.Equal(t, "pa55", cfg.DB.Password)
+		assert.Equal(t, []string{"t0ken"}, cfg.Tokens)
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"password": "secret://db-password"`)
+		assert.Contains(t, string(data), `"secret://api/token"`)
+		assert.NotContains(t, string(data), "pa55")
+		assert.NotContains(t, string(data), "t0ken")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Unsetenv(FilePathEnvName))
+		t.Setenv(DirEnvName, dir)
+		require.NoError(t, os.WriteFile(path.Join(dir, "config.json"), []byte(`{"db": {"password": "secret://missing"}}`), 0o600))
+
+		err := New(&secretConfig{}, WithSecretResolver[secretConfig](resolve))
+		assert.ErrorContains(t, err, "missing")
+	})
+}
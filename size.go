@@ -0,0 +1,125 @@
+package confix
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes that can be given in configuration files as a human-readable
+// string, e.g. "10MB" or "1.5GiB", as well as a plain number of bytes.
+// Decimal units (kB, MB, GB, TB, PB) are powers of 1000, binary units (KiB, MiB, GiB, TiB, PiB)
+// are powers of 1024. Units are case-insensitive.
+type ByteSize uint64
+
+// Byte size units.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB          = 1000 * KB
+	GB          = 1000 * MB
+	TB          = 1000 * GB
+	PB          = 1000 * TB
+
+	KiB ByteSize = 1024 * Byte
+	MiB          = 1024 * KiB
+	GiB          = 1024 * MiB
+	TiB          = 1024 * GiB
+	PiB          = 1024 * TiB
+)
+
+// byteSizeUnits maps lowercase unit names to their sizes.
+var byteSizeUnits = map[string]ByteSize{
+	"": Byte, "b": Byte,
+	"k": KB, "kb": KB, "ki": KiB, "kib": KiB,
+	"m": MB, "mb": MB, "mi": MiB, "mib": MiB,
+	"g": GB, "gb": GB, "gi": GiB, "gib": GiB,
+	"t": TB, "tb": TB, "ti": TiB, "tib": TiB,
+	"p": PB, "pb": PB, "pi": PiB, "pib": PiB,
+}
+
+// byteSizeNames lists the units used by ByteSize.String, from the largest to the smallest.
+var byteSizeNames = []struct {
+	size ByteSize
+	name string
+}{
+	{PiB, "PiB"}, {PB, "PB"},
+	{TiB, "TiB"}, {TB, "TB"},
+	{GiB, "GiB"}, {GB, "GB"},
+	{MiB, "MiB"}, {MB, "MB"},
+	{KiB, "KiB"}, {KB, "kB"},
+}
+
+// ParseByteSize parses a human-readable byte size such as "10MB", "1.5 GiB" or "512".
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	mult, ok := byteSizeUnits[unit]
+	if !ok || num == "" {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/uint64(mult) {
+			return 0, fmt.Errorf("byte size %q overflows", s)
+		}
+		return ByteSize(n) * mult, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if f*float64(mult) >= math.MaxUint64 {
+		return 0, fmt.Errorf("byte size %q overflows", s)
+	}
+	return ByteSize(f * float64(mult)), nil
+}
+
+// String returns the size in the largest unit that represents it exactly, e.g. "10MB" or "1MiB".
+func (b ByteSize) String() string {
+	for _, u := range byteSizeNames {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler accepting both strings and numbers of bytes.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return b.UnmarshalText([]byte(s))
+	}
+	var n uint64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid byte size %s", data)
+	}
+	*b = ByteSize(n)
+	return nil
+}
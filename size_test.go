@@ -0,0 +1,84 @@
+package confix
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for s, expected := range map[string]ByteSize{
+		"0":       0,
+		"512":     512,
+		"512B":    512,
+		"10MB":    10 * MB,
+		"10mb":    10 * MB,
+		"10 MiB":  10 * MiB,
+		"1.5GiB":  GiB + 512*MiB,
+		"2k":      2 * KB,
+		"3Ki":     3 * KiB,
+		" 1TB ":   TB,
+		"1PiB":    PiB,
+		"0.5kB":   500,
+		"16EiB":   0,
+		"":        0,
+		"MB":      0,
+		"10XB":    0,
+		"1.2.3MB": 0,
+		"-1MB":    0,
+	} {
+		t.Run(s, func(t *testing.T) {
+			size, err := ParseByteSize(s)
+			if expected == 0 && s != "0" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, expected, size)
+		})
+	}
+
+	_, err := ParseByteSize("20000000PB")
+	assert.ErrorContains(t, err, "overflows")
+}
+
+func TestByteSizeString(t *testing.T) {
+	assert.Equal(t, "0B", ByteSize(0).String())
+	assert.Equal(t, "123B", ByteSize(123).String())
+	assert.Equal(t, "10MB", (10 * MB).String())
+	assert.Equal(t, "1MiB", MiB.String())
+	assert.Equal(t, "2KiB", (2 * KiB).String())
+	assert.Equal(t, "1500kB", (1500 * KB).String())
+}
+
+func TestByteSizeDecoding(t *testing.T) {
+	type sizeConfig struct {
+		Max ByteSize `json:"max" yaml:"max" toml:"max" config:"max"`
+	}
+
+	var c sizeConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"max": "10MB"}`), &c))
+	assert.Equal(t, 10*MB, c.Max)
+	require.NoError(t, json.Unmarshal([]byte(`{"max": 1024}`), &c))
+	assert.Equal(t, ByteSize(1024), c.Max)
+	assert.Error(t, json.Unmarshal([]byte(`{"max": "ten"}`), &c))
+
+	require.NoError(t, yaml.Unmarshal([]byte("max: 2GiB\n"), &c))
+	assert.Equal(t, 2*GiB, c.Max)
+
+	_, err := toml.Decode(`max = "3kB"`, &c)
+	require.NoError(t, err)
+	assert.Equal(t, 3*KB, c.Max)
+
+	require.NoError(t, newIniDecoder(strings.NewReader("max = 4MiB\n")).Decode(&c))
+	assert.Equal(t, 4*MiB, c.Max)
+
+	data, err := json.Marshal(sizeConfig{Max: 5 * MB})
+	require.NoError(t, err)
+	assert.Equal(t, `{"max":"5MB"}`, string(data))
+}
@@ -0,0 +1,27 @@
+package confix
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// sniffFormat returns the extension of the first format decoding data into a value of the type T:
+// JSON when data starts with '{' or '[', after comments when they are allowed, then TOML and then YAML.
+// The value is thrown away.
+func sniffFormat[T any](data []byte, opts decodeOptions) (string, error) {
+	exts := []string{tomlExt, yamlExt}
+	trimmed := bytes.TrimSpace(data)
+	if opts.jsonComments {
+		trimmed = bytes.TrimSpace(stripJSONComments(trimmed))
+	}
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		exts = append([]string{jsonExt}, exts...)
+	}
+
+	for _, ext := range exts {
+		if err := decode(bytes.NewReader(data), ext, new(T), opts); err == nil {
+			return ext, nil
+		}
+	}
+	return "", fmt.Errorf("%w: format could not be detected", ErrUnsupportedExtension)
+}
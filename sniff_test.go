@@ -0,0 +1,88 @@
+package confix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffFormat(t *testing.T) {
+	type sniffConfig struct {
+		A string `json:"a" yaml:"a" toml:"a"`
+		B int    `json:"b" yaml:"b" toml:"b"`
+	}
+
+	for name, tc := range map[string]struct {
+		data string
+		ext  string
+	}{
+		"json":        {data: "  {\"a\": \"x\", \"b\": 1}", ext: jsonExt},
+		"toml":        {data: "a = \"x\"\nb = 1\n", ext: tomlExt},
+		"yaml":        {data: "a: x\nb: 1\n", ext: yamlExt},
+		"yaml flow":   {data: "{a: x, b: 1}", ext: yamlExt},
+		"toml tables": {data: "a = \"x\"\n[extra]\nc = 1\n", ext: tomlExt},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ext, err := sniffFormat[sniffConfig]([]byte(tc.data), decodeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.ext, ext)
+		})
+	}
+
+	_, err := sniffFormat[sniffConfig]([]byte("b: [unclosed\n"), decodeOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedExtension)
+}
+
+func TestWithFormatSniffing(t *testing.T) {
+	require.NoError(t, os.Unsetenv(DirEnvName))
+
+	t.Run("positive: file", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "app.conf")
+		require.NoError(t, os.WriteFile(fpath, []byte("a = \"toml\"\n"), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(testConfig)
+		assert.ErrorIs(t, New(cfg), ErrUnsupportedExtension)
+
+		require.NoError(t, New(cfg, WithFormatSniffing[testConfig]()))
+		assert.Equal(t, "toml", cfg.A)
+	})
+	t.Run("positive: write back", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "app.conf")
+		require.NoError(t, os.WriteFile(fpath, []byte("a = \"toml\"\n"), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg,
+			WithFormatSniffing[testConfig](),
+			WithOnLoad(func(c *testConfig) error {
+				c.A = "written"
+				return nil
+			}),
+			WithSyncingConfigToFiles[testConfig]()))
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, "a = \"written\"\n", string(data))
+	})
+	t.Run("positive: stdin", func(t *testing.T) {
+		t.Setenv(FilePathEnvName, stdinPath)
+		setStdin(t, `{"a": "json"}`)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg, WithFormatSniffing[testConfig]()))
+		assert.Equal(t, "json", cfg.A)
+	})
+	t.Run("negative: undetected format", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "app.conf")
+		require.NoError(t, os.WriteFile(fpath, []byte("a: [unclosed\n"), 0o600))
+		t.Setenv(FilePathEnvName, fpath)
+
+		err := New(new(testConfig), WithFormatSniffing[testConfig]())
+		assert.ErrorIs(t, err, ErrUnsupportedExtension)
+		assert.ErrorContains(t, err, fpath)
+	})
+}
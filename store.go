@@ -0,0 +1,49 @@
+package confix
+
+import "context"
+
+// Store provides goroutine-safe access to a configuration loaded by NewStore.
+type Store[T any] struct {
+	c *config[T]
+}
+
+// NewStore initializes and parses config the same way as New and returns a Store
+// guarding it against concurrent access.
+func NewStore[T any](cfg *T, opts ...Option[T]) (*Store[T], error) {
+	c, err := newConfig[T](context.Background(), cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Store[T]{c: c}, nil
+}
+
+// Get returns a copy of the current configuration.
+func (s *Store[T]) Get() T {
+	s.c.mu.RLock()
+	defer s.c.mu.RUnlock()
+	return *s.c.cfg
+}
+
+// Set persists v to the loaded configuration files and replaces the current configuration with it.
+// The current configuration is kept if writing fails.
+func (s *Store[T]) Set(v T) error {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+
+	tmp := &config[T]{
+		settings:   s.c.settings,
+		cfg:        &v,
+		paths:      s.c.paths,
+		candidates: s.c.candidates,
+		sources:    s.c.sources,
+		sniffed:    s.c.sniffed,
+		secretRefs: s.c.secretRefs,
+		sealed:     s.c.sealed,
+	}
+	if err := tmp.writeToFiles(); err != nil {
+		return err
+	}
+
+	*s.c.cfg = v
+	return nil
+}
@@ -0,0 +1,66 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "file"}`), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		s, err := NewStore(new(testConfig))
+		require.NoError(t, err)
+		assert.Equal(t, testConfig{A: "file"}, s.Get())
+
+		require.NoError(t, s.Set(testConfig{A: "set"}))
+		assert.Equal(t, testConfig{A: "set"}, s.Get())
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"a": "set"}`, string(data))
+	})
+	t.Run("positive: concurrent access", func(t *testing.T) {
+		s := &Store[testConfig]{c: &config[testConfig]{cfg: new(testConfig)}}
+		wg := sync.WaitGroup{}
+		for i := range 10 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, s.Set(testConfig{A: generateRandom(t, i+1)}))
+			}()
+			go func() {
+				defer wg.Done()
+				_ = s.Get()
+			}()
+		}
+		wg.Wait()
+	})
+	t.Run("negative: write failure keeps config", func(t *testing.T) {
+		s := &Store[testConfig]{c: &config[testConfig]{
+			cfg:     &testConfig{A: "before"},
+			paths:   []string{"unknown"},
+			sources: []string{"unknown"},
+		}}
+		assert.Error(t, s.Set(testConfig{A: "after"}))
+		assert.Equal(t, testConfig{A: "before"}, s.Get())
+	})
+	t.Run("negative: init error", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": `), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		s, err := NewStore(new(testConfig))
+		assert.Nil(t, s)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,109 @@
+package confix
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// decodeFile decodes the configuration data of the file p read from r into v,
+// or only the subtree of the data when it is set.
+func (c *config[T]) decodeFile(r io.Reader, ext string, v any, p string) error {
+	if c.subtree == "" {
+		return decode(r, ext, v, c.decodeOptions(p))
+	}
+	return c.decodeSubtree(r, ext, v, p)
+}
+
+// decodeSubtree decodes the data read from r into a generic map, descends to the subtree
+// and decodes it into v. The subtree is encoded again in the same format in between,
+// so that v is decoded with the options and the rules of the format.
+func (c *config[T]) decodeSubtree(r io.Reader, ext string, v any, p string) error {
+	f, ok := lookupFormat(ext)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedExtension, ext)
+	}
+
+	var root map[string]any
+	if err := decode(r, ext, &root, decodeOptions{jsonComments: c.jsonComments, source: p}); err != nil {
+		return err
+	}
+	sub, ok := lookupSubtree(root, strings.Split(c.subtree, "."))
+	if !ok {
+		return fmt.Errorf("error while decoding %s from %s: key %s not found", f.name, p, c.subtree)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := f.newEncoder(buf, EncoderOptions{}).Encode(sub); err != nil {
+		return fmt.Errorf("error while decoding %s from %s: %w", f.name, p, err)
+	}
+	return decode(buf, ext, v, c.decodeOptions(p+"#"+c.subtree))
+}
+
+// encodeSubtree encodes the configuration as the subtree of the file at fPath to w,
+// keeping the rest of the existing file. Keys of the file are written in the order of the encoder
+// for maps, e.g. sorted, and comments are not kept.
+func (c *config[T]) encodeSubtree(w io.Writer, fPath string) error {
+	ext := c.fileExt(fPath)
+	f, ok := lookupFormat(ext)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedExtension, ext)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.encode(buf, ext); err != nil {
+		return err
+	}
+	var sub map[string]any
+	if err := f.decode(buf, &sub, decodeOptions{}); err != nil {
+		return fmt.Errorf("error while encoding %s subtree: %w", f.name, err)
+	}
+
+	root := map[string]any{}
+	if current, err := os.ReadFile(fPath); err == nil && len(bytes.TrimSpace(current)) > 0 {
+		var r io.Reader = bytes.NewReader(current)
+		if c.aead != nil {
+			if r, err = decryptFile(c.aead, r); err != nil {
+				return fmt.Errorf("error while reading %s: %w", fPath, err)
+			}
+		}
+		if err = decode(r, ext, &root, decodeOptions{jsonComments: c.jsonComments, source: fPath}); err != nil {
+			return err
+		}
+	}
+	if c.jsonMeta {
+		// the header is added again when the file is written
+		delete(root, metaKey)
+	}
+	setSubtree(root, strings.Split(c.subtree, "."), sub)
+
+	return f.newEncoder(w, c.encoderOptions).Encode(root)
+}
+
+// lookupSubtree returns the map found in m by following the keys.
+func lookupSubtree(m map[string]any, keys []string) (map[string]any, bool) {
+	for _, k := range keys {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	return m, true
+}
+
+// setSubtree stores sub in m under the keys, creating intermediate maps and replacing
+// values that are not maps.
+func setSubtree(m map[string]any, keys []string, sub map[string]any) {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = sub
+}
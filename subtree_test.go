@@ -0,0 +1,78 @@
+package confix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSubtree(t *testing.T) {
+	type paymentsConfig struct {
+		Provider string `json:"provider" yaml:"provider" toml:"provider"`
+		Retries  int    `json:"retries" yaml:"retries" toml:"retries"`
+	}
+
+	load := func(t *testing.T, name, data string, opts ...Option[paymentsConfig]) (*paymentsConfig, string, error) {
+		fpath := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(fpath, []byte(data), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+		cfg := new(paymentsConfig)
+		return cfg, fpath, New(cfg, opts...)
+	}
+
+	for name, data := range map[string]string{
+		"config.yaml": "orders:\n  provider: other\nservices:\n  payments:\n    provider: stripe\n    retries: 3\n",
+		"config.json": `{"orders": {"provider": "other"}, "services": {"payments": {"provider": "stripe", "retries": 3}}}`,
+		"config.toml": "[orders]\nprovider = \"other\"\n[services.payments]\nprovider = \"stripe\"\nretries = 3\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, _, err := load(t, name, data, WithSubtree[paymentsConfig]("services.payments"))
+			require.NoError(t, err)
+			assert.Equal(t, &paymentsConfig{Provider: "stripe", Retries: 3}, cfg)
+		})
+	}
+
+	t.Run("positive: write back keeps other keys", func(t *testing.T) {
+		_, fpath, err := load(t, "config.yaml", "orders:\n  provider: other\npayments:\n  provider: stripe\n",
+			WithSubtree[paymentsConfig]("payments"),
+			WithOnLoad(func(c *paymentsConfig) error {
+				c.Retries = 5
+				return nil
+			}),
+			WithSyncingConfigToFiles[paymentsConfig]())
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.YAMLEq(t, "orders:\n  provider: other\npayments:\n  provider: stripe\n  retries: 5\n", string(data))
+	})
+	t.Run("positive: missing file is created with the subtree", func(t *testing.T) {
+		fpath := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := &paymentsConfig{Provider: "default"}
+		require.NoError(t, New(cfg, WithSubtree[paymentsConfig]("services.payments")))
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"services": {"payments": {"provider": "default", "retries": 0}}}`, string(data))
+	})
+	t.Run("negative: missing key", func(t *testing.T) {
+		_, _, err := load(t, "config.yaml", "orders:\n  provider: other\n", WithSubtree[paymentsConfig]("payments"))
+		assert.ErrorContains(t, err, "key payments not found")
+	})
+	t.Run("negative: strict decoding of the subtree", func(t *testing.T) {
+		_, _, err := load(t, "config.json", `{"orders": {"x": 1}, "payments": {"provider": "a", "typo": 1}}`,
+			WithSubtree[paymentsConfig]("payments"), WithStrictDecoding[paymentsConfig]())
+		assert.ErrorContains(t, err, "typo")
+	})
+	t.Run("negative: invalid key path", func(t *testing.T) {
+		_, _, err := load(t, "config.json", `{}`, WithSubtree[paymentsConfig]("services..payments"))
+		assert.ErrorContains(t, err, "invalid key path")
+	})
+}
@@ -0,0 +1,33 @@
+package confix
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/BurntSushi/toml"
+)
+
+// sortedTOMLEncoder writes TOML with keys sorted alphabetically at every level regardless of
+// the order of struct fields, so that identical configurations produce byte-identical output.
+type sortedTOMLEncoder struct {
+	w      io.Writer
+	indent string
+}
+
+// Encode writes the TOML encoding of v to the underlying writer. v is encoded, decoded into
+// a map and encoded again, as maps are written with sorted keys.
+func (e *sortedTOMLEncoder) Encode(v interface{}) error {
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+
+	m := map[string]any{}
+	if _, err := toml.NewDecoder(buf).Decode(&m); err != nil {
+		return err
+	}
+
+	enc := toml.NewEncoder(e.w)
+	enc.Indent = e.indent
+	return enc.Encode(m)
+}
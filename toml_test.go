@@ -0,0 +1,70 @@
+package confix
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedTOMLEncoder(t *testing.T) {
+	type server struct {
+		Port int    `toml:"port"`
+		Host string `toml:"host"`
+	}
+	type first struct {
+		Zone    string        `toml:"zone"`
+		Server  server        `toml:"server"`
+		Timeout time.Duration `toml:"timeout"`
+		Started time.Time     `toml:"started"`
+		Alpha   []int         `toml:"alpha"`
+	}
+	type second struct {
+		Alpha   []int         `toml:"alpha"`
+		Server  server        `toml:"server"`
+		Started time.Time     `toml:"started"`
+		Timeout time.Duration `toml:"timeout"`
+		Zone    string        `toml:"zone"`
+	}
+
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := &first{Zone: "eu", Server: server{Port: 80, Host: "h"}, Timeout: time.Second, Started: started, Alpha: []int{1, 2}}
+	b := &second{Zone: "eu", Server: server{Port: 80, Host: "h"}, Timeout: time.Second, Started: started, Alpha: []int{1, 2}}
+
+	bufA := &bytes.Buffer{}
+	require.NoError(t, (&sortedTOMLEncoder{w: bufA, indent: "  "}).Encode(a))
+	bufB := &bytes.Buffer{}
+	require.NoError(t, (&sortedTOMLEncoder{w: bufB, indent: "  "}).Encode(b))
+
+	assert.Equal(t, bufA.String(), bufB.String())
+	assert.Equal(t, `alpha = [1, 2]
+started = 2024-01-02T03:04:05Z
+timeout = "1s"
+zone = "eu"
+
+[server]
+  host = "h"
+  port = 80
+`, bufA.String())
+
+	decoded := &first{}
+	_, err := toml.Decode(bufA.String(), decoded)
+	require.NoError(t, err)
+	assert.Equal(t, a, decoded)
+}
+
+func TestWithEncoderOptionsTOMLSortKeys(t *testing.T) {
+	type unsorted struct {
+		B string `toml:"b"`
+		A string `toml:"a"`
+	}
+	c := &config[unsorted]{cfg: &unsorted{B: "b", A: "a"}}
+	require.NoError(t, WithEncoderOptions[unsorted](EncoderOptions{TOMLSortKeys: true}).apply(c))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.encode(buf, ".toml"))
+	assert.Equal(t, "a = \"a\"\nb = \"b\"\n", buf.String())
+}
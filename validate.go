@@ -0,0 +1,146 @@
+package confix
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// validateTagName is the struct tag holding validation rules checked by WithTagValidation.
+const validateTagName = "validate"
+
+// ErrInvalidField is returned for every field whose value breaks a rule of its validate tag.
+var ErrInvalidField = errors.New("invalid field")
+
+// checkTags walks the struct v and returns an error for every field breaking a rule
+// of its validate tag, e.g. `validate:"min=1,max=65535"`.
+// Errors are aggregated with errors.Join; field names are config keys joined with dots.
+func checkTags(v reflect.Value, prefix string) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var resultErr error
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, _ := parseConfigTag(f)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if rules := f.Tag.Get(validateTagName); rules != "" {
+			resultErr = errors.Join(resultErr, checkRules(fv, key, rules))
+		}
+		if fv.Kind() == reflect.Struct {
+			resultErr = errors.Join(resultErr, checkTags(fv, key))
+		}
+	}
+
+	return resultErr
+}
+
+// checkRules checks v against the comma-separated rules of a validate tag.
+// Supported rules are min=N and max=N, which limit numbers and the length of strings,
+// slices and maps, and oneof=a b c, which limits the value to the space-separated options.
+func checkRules(v reflect.Value, key, rules string) error {
+	var resultErr error
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		msg, err := checkRule(v, name, arg)
+		if err != nil {
+			resultErr = errors.Join(resultErr, fmt.Errorf("error while parsing rule %q of %s: %w", rule, key, err))
+			continue
+		}
+		if msg != "" {
+			resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s: %s", ErrInvalidField, key, msg))
+		}
+	}
+	return resultErr
+}
+
+// checkRule returns a description of the violation if v breaks the rule and an empty string otherwise.
+func checkRule(v reflect.Value, name, arg string) (string, error) {
+	switch name {
+	case "min", "max":
+		c, err := compareWith(v, arg)
+		if err != nil {
+			return "", err
+		}
+		if name == "min" && c < 0 {
+			return "must be at least " + arg, nil
+		}
+		if name == "max" && c > 0 {
+			return "must be at most " + arg, nil
+		}
+	case "oneof":
+		if !v.Comparable() {
+			return "", fmt.Errorf("unsupported field type: %s", v.Type())
+		}
+		options := strings.Fields(arg)
+		for _, o := range options {
+			ov, err := parseRuleValue(v.Type(), o)
+			if err != nil {
+				return "", err
+			}
+			if ov.Equal(v) {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.Join(options, ", "), nil
+	default:
+		return "", fmt.Errorf("unknown rule %q", name)
+	}
+	return "", nil
+}
+
+// compareWith compares v with arg, or the length of v for strings, slices and maps.
+func compareWith(v reflect.Value, arg string) (int, error) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return 0, err
+		}
+		return cmp.Compare(v.Len(), n), nil
+	}
+
+	av, err := parseRuleValue(v.Type(), arg)
+	if err != nil {
+		return 0, err
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(v.Int(), av.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(v.Uint(), av.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(v.Float(), av.Float()), nil
+	default:
+		return 0, fmt.Errorf("unsupported field type: %s", v.Type())
+	}
+}
+
+// parseRuleValue parses the argument of a rule as a value of the type t,
+// so that e.g. durations can be limited with `validate:"max=1m"`.
+func parseRuleValue(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if err := setFieldFromString(v, s); err != nil {
+		return reflect.Value{}, err
+	}
+	return v, nil
+}
@@ -0,0 +1,96 @@
+package confix
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tagValidationConfig struct {
+	Port    int           `config:"port" json:"port" validate:"min=1,max=65535"`
+	Level   string        `config:"level" json:"level" validate:"oneof=debug info warn error"`
+	Timeout time.Duration `config:"timeout" json:"timeout" validate:"min=1s,max=1m"`
+	Ratio   *float64      `config:"ratio" json:"ratio" validate:"max=1"`
+	Hosts   []string      `config:"hosts" json:"hosts" validate:"min=1"`
+	DB      struct {
+		Name string `config:"name" json:"name" validate:"min=3"`
+	} `config:"db" json:"db"`
+}
+
+func validTagValidationConfig() *tagValidationConfig {
+	cfg := &tagValidationConfig{
+		Port:    8080,
+		Level:   "info",
+		Timeout: 5 * time.Second,
+		Hosts:   []string{"a"},
+	}
+	cfg.DB.Name = "app"
+	return cfg
+}
+
+func TestCheckTags(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		cfg := validTagValidationConfig()
+		assert.NoError(t, checkTags(reflect.ValueOf(cfg).Elem(), ""))
+
+		ratio := 0.5
+		cfg.Ratio = &ratio
+		assert.NoError(t, checkTags(reflect.ValueOf(cfg).Elem(), ""))
+	})
+	t.Run("negative: all violations are reported", func(t *testing.T) {
+		ratio := 1.5
+		cfg := &tagValidationConfig{
+			Port:    70000,
+			Level:   "trace",
+			Timeout: time.Hour,
+			Ratio:   &ratio,
+		}
+		cfg.DB.Name = "a"
+		err := checkTags(reflect.ValueOf(cfg).Elem(), "")
+		if assert.Error(t, err) {
+			assert.ErrorIs(t, err, ErrInvalidField)
+			assert.Contains(t, err.Error(), "port: must be at most 65535")
+			assert.Contains(t, err.Error(), "level: must be one of debug, info, warn, error")
+			assert.Contains(t, err.Error(), "timeout: must be at most 1m")
+			assert.Contains(t, err.Error(), "ratio: must be at most 1")
+			assert.Contains(t, err.Error(), "hosts: must be at least 1")
+			assert.Contains(t, err.Error(), "db.name: must be at least 3")
+		}
+	})
+	t.Run("negative: malformed rules", func(t *testing.T) {
+		type malformedConfig struct {
+			A int      `validate:"min=x"`
+			B string   `validate:"email"`
+			C []string `validate:"oneof=a b"`
+		}
+		err := checkTags(reflect.ValueOf(&malformedConfig{}).Elem(), "")
+		if assert.Error(t, err) {
+			assert.NotErrorIs(t, err, ErrInvalidField)
+			assert.Contains(t, err.Error(), `error while parsing rule "min=x" of A`)
+			assert.Contains(t, err.Error(), `unknown rule "email"`)
+			assert.Contains(t, err.Error(), `error while parsing rule "oneof=a b" of C: unsupported field type: []string`)
+		}
+	})
+}
+
+func TestWithTagValidation(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"port": 0, "level": "info"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	cfg := validTagValidationConfig()
+	err := New(cfg, WithTagValidation[tagValidationConfig]())
+	assert.ErrorIs(t, err, ErrInvalidField)
+	assert.ErrorContains(t, err, "port: must be at least 1")
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"port": 443, "level": "warn"}`), 0o600))
+	cfg = validTagValidationConfig()
+	require.NoError(t, New(cfg, WithTagValidation[tagValidationConfig]()))
+	assert.Equal(t, 443, cfg.Port)
+}
@@ -0,0 +1,243 @@
+package confix
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is the time window during which successive file events are coalesced into a single reload
+// unless WithDebounce sets another one. Editors often write a file several times in a row on save.
+const DefaultDebounce = 100 * time.Millisecond
+
+// Watch starts watching the configuration files resolved the same way as in New and reloads
+// cfg whenever one of them changes. Files are decoded into a copy of the current configuration
+// which is swapped in under a mutex before onChange is called. Missing files are watched for
+// but not created. Options configuring the instance, such as WithDebounce, apply to the watcher;
+// transform and validate options are applied to every reload, writing options are not.
+// The returned stop function tears down the watcher and is safe to call more than once.
+func Watch[T any](cfg *T, onChange func(*T), opts ...Option[T]) (stop func(), err error) {
+	stop, err = startWatch(cfg, opts, func(c *config[T], err error) {
+		if err != nil {
+			c.logf("ERROR: reloading config; err=%v", err)
+			var skipped skippedSourcesError
+			if !errors.As(err, &skipped) {
+				return
+			}
+		}
+		if onChange != nil {
+			onChange(c.cfg)
+		}
+	})
+	return stop, err
+}
+
+// ReloadEvent reports the outcome of a reload triggered by WatchChan.
+type ReloadEvent[T any] struct {
+	// Config is a snapshot of the configuration after the reload. When the reload failed
+	// it holds the previous configuration, which remains active.
+	Config *T
+	// Err is the error of a failed reload, e.g. a syntax error in an edited file
+	Err error
+}
+
+// WatchChan watches the configuration files like Watch but reports every reload on the returned channel
+// instead of calling a function, including failed ones, so that errors of bad edits can be handled.
+// Each event carries a copy of the configuration, which is left unchanged on failure.
+// Events are delivered one at a time; reloads wait until the previous event is received.
+// The channel is closed once stop is called.
+func WatchChan[T any](cfg *T, opts ...Option[T]) (<-chan ReloadEvent[T], func(), error) {
+	events := make(chan ReloadEvent[T])
+	done := make(chan struct{})
+
+	stop, err := startWatch(cfg, opts, func(c *config[T], err error) {
+		c.mu.RLock()
+		snapshot := *c.cfg
+		c.mu.RUnlock()
+
+		select {
+		case events <- ReloadEvent[T]{Config: &snapshot, Err: err}:
+		case <-done:
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	once := sync.Once{}
+	return events, func() {
+		once.Do(func() {
+			close(done)
+			stop()
+			close(events)
+		})
+	}, nil
+}
+
+// startWatch starts watching the configuration files of cfg configured by opts and calls notify
+// with the result of every reload from the watching goroutine.
+func startWatch[T any](cfg *T, opts []Option[T], notify func(c *config[T], err error)) (func(), error) {
+	c := &config[T]{
+		settings: newSettings(),
+		cfg:      cfg,
+		paths:    []string{},
+	}
+	for _, f := range opts {
+		if o, ok := f.(afterOption[T]); ok {
+			c.after = append(c.after, o)
+			continue
+		}
+		if err := f.apply(c); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(c.after, func(i, j int) bool {
+		return c.after[i].phase < c.after[j].phase
+	})
+	// watching only reads files, missing ones are picked up once they are created
+	c.noCreate = true
+	if err := c.getConfigPaths(); err != nil {
+		return nil, err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Directories are watched instead of files because atomic writes replace the file,
+	// which drops watches on the file itself.
+	watched := make(map[string]struct{}, len(c.paths))
+	dirs := make(map[string]struct{}, len(c.paths))
+	for _, p := range c.paths {
+		if p == stdinPath {
+			continue
+		}
+		abs, absErr := filepath.Abs(p)
+		if absErr != nil {
+			_ = w.Close()
+			return nil, absErr
+		}
+		watched[abs] = struct{}{}
+
+		dir := filepath.Dir(abs)
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+		if err = w.Add(dir); err != nil {
+			_ = w.Close()
+			return nil, err
+		}
+		dirs[dir] = struct{}{}
+	}
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.watch(w, watched, notify, done)
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			close(done)
+			_ = w.Close()
+			wg.Wait()
+		})
+	}, nil
+}
+
+// watch handles watcher events until done is closed, reloading the configuration
+// once no further events for the watched paths arrive within the debounce window
+// and passing the result to notify.
+func (c *config[T]) watch(w *fsnotify.Watcher, watched map[string]struct{},
+	notify func(*config[T], error), done <-chan struct{}) {
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-done:
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if _, ok = watched[filepath.Clean(ev.Name)]; !ok || !ev.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			debounce = time.After(c.debounceWindow())
+		case <-debounce:
+			debounce = nil
+			notify(c, c.reload(context.Background()))
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			c.logf("ERROR: watching config files; err=%v", err)
+		}
+	}
+}
+
+// debounceWindow returns the window set by WithDebounce, or DefaultDebounce when it isn't set.
+func (c *config[T]) debounceWindow() time.Duration {
+	if c.debounce > 0 {
+		return c.debounce
+	}
+	return DefaultDebounce
+}
+
+// reload decodes the configuration files into a copy of the current configuration
+// and swaps it in under the mutex once it is validated. The current configuration is kept on failure.
+func (c *config[T]) reload(ctx context.Context) error {
+	fresh, err := c.loadCopy(ctx)
+	if fresh == nil {
+		return err
+	}
+
+	c.mu.Lock()
+	*c.cfg = *fresh
+	c.mu.Unlock()
+	return err
+}
+
+// loadCopy decodes the configuration files into a copy of the current configuration and returns it
+// once the transform and validate options given at initialization succeed on it; persist options
+// are not applied. With WithContinueOnError the copy is returned together with the errors of skipped sources.
+func (c *config[T]) loadCopy(ctx context.Context) (*T, error) {
+	fresh := new(T)
+	c.mu.RLock()
+	*fresh = *c.cfg
+	c.mu.RUnlock()
+
+	tmp := &config[T]{
+		settings:      c.settings,
+		cfg:           fresh,
+		paths:         c.paths,
+		layerPaths:    c.layerPaths,
+		explicitPaths: c.explicitPaths,
+	}
+	var skipped skippedSourcesError
+	if err := tmp.load(ctx); err != nil && !errors.As(err, &skipped) {
+		return nil, err
+	}
+
+	for _, o := range c.after {
+		if o.phase >= phasePersist {
+			break
+		}
+		if err := o.apply(tmp); err != nil {
+			return nil, err
+		}
+	}
+
+	if skipped != nil {
+		return fresh, skipped
+	}
+	return fresh, nil
+}
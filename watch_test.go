@@ -0,0 +1,130 @@
+package confix
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	t.Run("positive", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "before"}`), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(testConfig)
+		require.NoError(t, New(cfg))
+		require.Equal(t, "before", cfg.A)
+
+		changed := make(chan testConfig, 1)
+		stop, err := Watch(cfg, func(c *testConfig) {
+			changed <- *c
+		})
+		require.NoError(t, err)
+		defer stop()
+
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "after"}`), 0o600))
+
+		select {
+		case got := <-changed:
+			assert.Equal(t, "after", got.A)
+		case <-time.After(5 * time.Second):
+			t.Fatal("config was not reloaded")
+		}
+	})
+	t.Run("positive: failed reload keeps config", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "before"}`), 0o600))
+		c := &config[testConfig]{
+			cfg:   &testConfig{A: "before"},
+			paths: []string{fpath},
+		}
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": `), 0o600))
+		assert.Error(t, c.reload(context.Background()))
+		assert.Equal(t, "before", c.cfg.A)
+	})
+	t.Run("positive: stop twice", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "before"}`), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		stop, err := Watch(new(testConfig), nil)
+		require.NoError(t, err)
+		stop()
+		stop()
+	})
+	t.Run("positive: missing file is not created", func(t *testing.T) {
+		fpath := path.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		changed := make(chan testConfig, 1)
+		stop, err := Watch(new(testConfig), func(c *testConfig) {
+			changed <- *c
+		}, WithDebounce[testConfig](10*time.Millisecond))
+		require.NoError(t, err)
+		defer stop()
+		assert.NoFileExists(t, fpath)
+
+		require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "created"}`), 0o600))
+		select {
+		case got := <-changed:
+			assert.Equal(t, "created", got.A)
+		case <-time.After(5 * time.Second):
+			t.Fatal("config was not reloaded")
+		}
+	})
+	t.Run("negative: invalid debounce", func(t *testing.T) {
+		_, err := Watch(new(testConfig), nil, WithDebounce[testConfig](0))
+		assert.ErrorContains(t, err, "invalid debounce window")
+	})
+}
+
+func TestWatchChan(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "before"}`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	cfg := new(testConfig)
+	require.NoError(t, New(cfg))
+
+	events, stop, err := WatchChan(cfg)
+	require.NoError(t, err)
+	defer stop()
+
+	next := func() ReloadEvent[testConfig] {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("config was not reloaded")
+			return ReloadEvent[testConfig]{}
+		}
+	}
+
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": "after"}`), 0o600))
+	ev := next()
+	require.NoError(t, ev.Err)
+	assert.Equal(t, "after", ev.Config.A)
+
+	// a bad edit is reported and the previous config stays active
+	require.NoError(t, os.WriteFile(fpath, []byte(`{"a": `), 0o600))
+	ev = next()
+	assert.ErrorContains(t, ev.Err, fpath)
+	assert.Equal(t, "after", ev.Config.A)
+
+	stop()
+	stop()
+	_, ok := <-events
+	assert.False(t, ok)
+	assert.Equal(t, "after", cfg.A)
+}
@@ -0,0 +1,334 @@
+package confix
+
+import (
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// commentTagName is the struct tag holding the comment written above a key in YAML files.
+const commentTagName = "comment"
+
+// yamlEncoder writes YAML documents preceded by a header comment and a document start marker,
+// and with the comment tags of struct fields written above their keys.
+type yamlEncoder struct {
+	w    io.Writer
+	opts EncoderOptions
+}
+
+// Encode writes the YAML encoding of v to the underlying writer.
+func (e *yamlEncoder) Encode(v interface{}) error {
+	node, err := encodeYAMLNode(v, e.opts)
+	if err != nil {
+		return err
+	}
+
+	if e.opts.YAMLHeader != "" {
+		var b strings.Builder
+		for _, line := range strings.Split(e.opts.YAMLHeader, "\n") {
+			b.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+		}
+		b.WriteString("---\n")
+		if _, err := io.WriteString(e.w, b.String()); err != nil {
+			return err
+		}
+	}
+
+	enc := yaml.NewEncoder(e.w)
+	enc.SetIndent(e.opts.YAMLIndent)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// addYAMLComments sets the comment tags of the fields of the struct type t
+// as head comments of the matching keys of the mapping node, recursing into nested values.
+func addYAMLComments(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			addYAMLComments(n, t)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for _, n := range node.Content {
+			addYAMLComments(n, t.Elem())
+		}
+	case yaml.MappingNode:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		fields := map[string]reflect.StructField{}
+		collectYAMLFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			f, ok := fields[node.Content[i].Value]
+			if !ok {
+				continue
+			}
+			if c := f.Tag.Get(commentTagName); c != "" {
+				node.Content[i].HeadComment = c
+			}
+			addYAMLComments(node.Content[i+1], f.Type)
+		}
+	}
+}
+
+// collectYAMLFields stores the exported fields of the struct type t in fields by their YAML keys,
+// flattening fields marked with the inline flag.
+func collectYAMLFields(t reflect.Type, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, flags, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if hasTagOption(strings.Split(flags, ","), "inline") && f.Type.Kind() == reflect.Struct {
+			collectYAMLFields(f.Type, fields)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+}
+
+// encodeYAMLRoundTrip writes the configuration to w as an update of the YAML document data,
+// so that comments, anchors, aliases and merge keys of the document survive the write.
+// Only values differing from the configuration are changed; it reports false when data
+// isn't a YAML document that can be updated.
+func (c *config[T]) encodeYAMLRoundTrip(w io.Writer, data []byte) (bool, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return false, nil
+	}
+
+	v, err := c.writtenValue()
+	if err != nil {
+		return false, err
+	}
+	node, err := encodeYAMLNode(v, c.encoderOptions)
+	if err != nil {
+		return false, err
+	}
+	if c.keyPolicy != nil {
+		toPolicyYAMLNode(node, reflect.TypeOf(v), c.keyPolicy)
+	}
+
+	fieldType := func(t reflect.Type, key string) reflect.Type {
+		if f, ok := lookupYAMLField(t, key); ok {
+			return f.Type
+		}
+		return nil
+	}
+	if c.keyPolicy != nil {
+		fieldType = func(t reflect.Type, key string) reflect.Type {
+			return policyFields(t, c.keyPolicy, "yaml")[key].typ
+		}
+	}
+	if err = updateYAMLNode(doc.Content[0], node, reflect.TypeOf(v), fieldType); err != nil {
+		return false, err
+	}
+	clearMergeTags(doc)
+
+	indent := 2
+	if c.encoderOptions.YAMLIndent > 0 {
+		indent = c.encoderOptions.YAMLIndent
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(indent)
+	if err := enc.Encode(doc); err != nil {
+		return false, err
+	}
+	return true, enc.Close()
+}
+
+// updateYAMLNode changes dst, a node of an existing document, to hold the value of src,
+// a node encoded from a value of the type t. Nodes holding equal values are left untouched,
+// mappings are updated key by key, sequences item by item and scalars in place,
+// so that anchors and comments are kept. fieldType returns the type of the field of a struct type
+// encoded under a key, or nil for unknown keys; t is nil for values of unknown types.
+func updateYAMLNode(dst, src *yaml.Node, t reflect.Type, fieldType func(reflect.Type, string) reflect.Type) error {
+	equal, err := yamlNodesEqual(dst, src)
+	if err != nil || equal {
+		return err
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		return updateYAMLMapping(dst, src, t, fieldType)
+	case dst.Kind == yaml.AliasNode && dst.Alias.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		// keep sharing the anchored mapping and override the differing keys locally
+		alias := *dst
+		*dst = yaml.Node{
+			Kind:        yaml.MappingNode,
+			Tag:         "!!map",
+			HeadComment: alias.HeadComment,
+			LineComment: alias.LineComment,
+			FootComment: alias.FootComment,
+			Content:     []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!merge", Value: "<<"}, &alias},
+		}
+		alias.HeadComment, alias.LineComment, alias.FootComment = "", "", ""
+		return updateYAMLMapping(dst, src, t, fieldType)
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		// update items in place so that comments of unchanged items are kept
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		n := min(len(dst.Content), len(src.Content))
+		for i := 0; i < n; i++ {
+			if err = updateYAMLNode(dst.Content[i], src.Content[i], et, fieldType); err != nil {
+				return err
+			}
+		}
+		dst.Content = append(dst.Content[:n], src.Content[n:]...)
+	case dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode:
+		dst.Value, dst.Tag = src.Value, src.Tag
+		if src.Style != 0 || src.Tag != "!!str" {
+			dst.Style = src.Style
+		}
+	default:
+		// replaced nodes keep their comments, aliased nodes their anchor
+		head, line, foot, anchor := dst.HeadComment, dst.LineComment, dst.FootComment, dst.Anchor
+		*dst = *src
+		dst.Anchor = anchor
+		if head != "" || line != "" || foot != "" {
+			dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		}
+	}
+	return nil
+}
+
+// updateYAMLMapping updates the values of the mapping node dst with the values of the mapping node src,
+// encoded from a value of the type t. Keys inherited through merge keys are overridden in dst instead of
+// changing the merged mapping. Keys missing from src are removed from mappings of Go maps, so that deleted
+// entries don't come back on the next load, and kept in mappings of structs, where they are unknown keys.
+func updateYAMLMapping(dst, src *yaml.Node, t reflect.Type, fieldType func(reflect.Type, string) reflect.Type) error {
+	if t != nil && t.Kind() == reflect.Map {
+		content := dst.Content[:0]
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key := dst.Content[i]
+			if isYAMLMergeKey(key) || yamlMappingValue(src, key.Value, false) != nil {
+				content = append(content, key, dst.Content[i+1])
+			}
+		}
+		dst.Content = content
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, val := src.Content[i], src.Content[i+1]
+		var vt reflect.Type
+		switch {
+		case t == nil:
+		case t.Kind() == reflect.Map:
+			vt = t.Elem()
+		case t.Kind() == reflect.Struct:
+			vt = fieldType(t, key.Value)
+		}
+		if v := yamlMappingValue(dst, key.Value, false); v != nil {
+			if err := updateYAMLNode(v, val, vt, fieldType); err != nil {
+				return err
+			}
+			continue
+		}
+		if v := yamlMappingValue(dst, key.Value, true); v != nil {
+			equal, err := yamlNodesEqual(v, val)
+			if err != nil {
+				return err
+			}
+			if equal {
+				continue
+			}
+		}
+		dst.Content = append(dst.Content, key, val)
+	}
+	return nil
+}
+
+// yamlMappingValue returns the value of the key in the mapping node m, or nil when it is missing.
+// With merged set only values inherited through merge keys are looked up, following the YAML
+// merge rules: explicit keys of merged mappings first, earlier mappings of a sequence first.
+func yamlMappingValue(m *yaml.Node, key string, merged bool) *yaml.Node {
+	if m.Kind == yaml.AliasNode {
+		m = m.Alias
+	}
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if !merged && !isYAMLMergeKey(m.Content[i]) && m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	if !merged {
+		return nil
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if !isYAMLMergeKey(m.Content[i]) {
+			continue
+		}
+		sources := []*yaml.Node{m.Content[i+1]}
+		if m.Content[i+1].Kind == yaml.SequenceNode {
+			sources = m.Content[i+1].Content
+		}
+		for _, s := range sources {
+			if v := yamlMappingValue(s, key, false); v != nil {
+				return v
+			}
+			if v := yamlMappingValue(s, key, true); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// isYAMLMergeKey reports whether the key node is the << merge key.
+func isYAMLMergeKey(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && (key.Tag == "!!merge" || key.Tag == "" && key.Value == "<<")
+}
+
+// clearMergeTags removes the explicit tags of merge keys in the tree of node,
+// which the encoder would otherwise write as "!!merge <<".
+func clearMergeTags(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if isYAMLMergeKey(node.Content[i]) {
+				node.Content[i].Tag = ""
+			}
+		}
+	}
+	for _, n := range node.Content {
+		clearMergeTags(n)
+	}
+}
+
+// yamlNodesEqual reports whether the nodes a and b decode to equal values.
+func yamlNodesEqual(a, b *yaml.Node) (bool, error) {
+	var av, bv any
+	if err := a.Decode(&av); err != nil {
+		return false, err
+	}
+	if err := b.Decode(&bv); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(av, bv), nil
+}
@@ -0,0 +1,293 @@
+package confix
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLEncoder(t *testing.T) {
+	type server struct {
+		Host string `yaml:"host" comment:"Address to listen on"`
+		Port int    `yaml:"port"`
+	}
+	type base struct {
+		Name string `yaml:"name" comment:"Service name"`
+	}
+	type yamlConfig struct {
+		Base    base     `yaml:",inline"`
+		Server  server   `yaml:"server" comment:"HTTP server"`
+		Backups []server `yaml:"backups"`
+		Debug   bool     `comment:"Verbose logging"`
+	}
+	cfg := &yamlConfig{
+		Base:    base{Name: "app"},
+		Server:  server{Host: "localhost", Port: 8080},
+		Backups: []server{{Host: "backup", Port: 8081}},
+	}
+
+	buf := &bytes.Buffer{}
+	enc := &yamlEncoder{w: buf, opts: EncoderOptions{
+		YAMLIndent:   2,
+		YAMLHeader:   "generated by confix\n\ndo not edit",
+		YAMLComments: true,
+	}}
+	require.NoError(t, enc.Encode(cfg))
+	assert.Equal(t, `# generated by confix
+#
+# do not edit
+---
+# Service name
+name: app
+# HTTP server
+server:
+  # Address to listen on
+  host: localhost
+  port: 8080
+backups:
+  - # Address to listen on
+    host: backup
+    port: 8081
+# Verbose logging
+debug: false
+`, buf.String())
+
+	decoded := &yamlConfig{}
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), decoded))
+	assert.Equal(t, cfg, decoded)
+
+	buf.Reset()
+	enc = &yamlEncoder{w: buf, opts: EncoderOptions{YAMLIndent: 2, YAMLHeader: "generated"}}
+	require.NoError(t, enc.Encode(&server{Host: "h"}))
+	assert.Equal(t, "# generated\n---\nhost: h\nport: 0\n", buf.String())
+}
+
+func TestWithEncoderOptionsYAMLComments(t *testing.T) {
+	type commented struct {
+		A string `yaml:"a" comment:"The A"`
+	}
+	c := &config[commented]{cfg: &commented{A: "x"}}
+	require.NoError(t, WithEncoderOptions[commented](EncoderOptions{YAMLComments: true, YAMLIndent: 4}).apply(c))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.encode(buf, ".yaml"))
+	assert.Equal(t, "# The A\na: x\n", buf.String())
+}
+
+func TestWithYAMLRoundTrip(t *testing.T) {
+	type db struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	type roundTripConfig struct {
+		Defaults db       `yaml:"defaults"`
+		Primary  db       `yaml:"primary"`
+		Replica  db       `yaml:"replica"`
+		Tags     []string `yaml:"tags"`
+		Name     string   `yaml:"name"`
+	}
+
+	const original = `defaults: &db
+  host: localhost
+  port: 5432
+primary:
+  <<: *db
+  host: primary
+replica: *db
+tags: [a, b]
+name: app
+`
+
+	load := func(t *testing.T, data string, opts ...Option[roundTripConfig]) (*roundTripConfig, string) {
+		t.Helper()
+		fpath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(fpath, []byte(data), 0o600))
+		require.NoError(t, os.Unsetenv(DirEnvName))
+		t.Setenv(FilePathEnvName, fpath)
+
+		cfg := new(roundTripConfig)
+		require.NoError(t, New(cfg, opts...))
+		return cfg, fpath
+	}
+
+	t.Run("positive: unchanged", func(t *testing.T) {
+		_, fpath := load(t, original,
+			WithYAMLRoundTrip[roundTripConfig](),
+			WithSyncingConfigToFiles[roundTripConfig]())
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, original, string(data))
+	})
+	t.Run("positive: changed values", func(t *testing.T) {
+		_, fpath := load(t, original,
+			WithYAMLRoundTrip[roundTripConfig](),
+			WithOnLoad(func(c *roundTripConfig) error {
+				c.Primary.Port = 6543
+				c.Replica.Host = "replica"
+				c.Name = "renamed"
+				return nil
+			}),
+			WithSyncingConfigToFiles[roundTripConfig]())
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Equal(t, `defaults: &db
+  host: localhost
+  port: 5432
+primary:
+  <<: *db
+  host: primary
+  port: 6543
+replica:
+  <<: *db
+  host: replica
+tags: [a, b]
+name: renamed
+`, string(data))
+
+		cfg := new(roundTripConfig)
+		require.NoError(t, New(cfg))
+		assert.Equal(t, db{Host: "localhost", Port: 5432}, cfg.Defaults)
+		assert.Equal(t, db{Host: "primary", Port: 6543}, cfg.Primary)
+		assert.Equal(t, db{Host: "replica", Port: 5432}, cfg.Replica)
+		assert.Equal(t, "renamed", cfg.Name)
+	})
+	t.Run("positive: anchored value changed", func(t *testing.T) {
+		_, fpath := load(t, original,
+			WithYAMLRoundTrip[roundTripConfig](),
+			WithOnLoad(func(c *roundTripConfig) error {
+				c.Defaults.Port = 1
+				c.Replica.Port = 1
+				return nil
+			}),
+			WithSyncingConfigToFiles[roundTripConfig]())
+
+		cfg := new(roundTripConfig)
+		require.NoError(t, New(cfg))
+		assert.Equal(t, db{Host: "localhost", Port: 1}, cfg.Defaults)
+		assert.Equal(t, db{Host: "primary", Port: 5432}, cfg.Primary)
+		assert.Equal(t, db{Host: "localhost", Port: 1}, cfg.Replica)
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "replica: *db\n")
+	})
+	t.Run("positive: disabled", func(t *testing.T) {
+		_, fpath := load(t, original, WithSyncingConfigToFiles[roundTripConfig]())
+
+		data, err := os.ReadFile(fpath)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "*db")
+	})
+}
+
+func TestWithYAMLRoundTripComments(t *testing.T) {
+	type server struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	type commentedConfig struct {
+		Server  server   `yaml:"server"`
+		Origins []string `yaml:"origins"`
+		Debug   bool     `yaml:"debug"`
+		Level   string   `yaml:"level" comment:"Log level"`
+	}
+
+	fpath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(fpath, []byte(`# Service configuration.
+# Edited by hand.
+
+server:
+  # public interface
+  host: 0.0.0.0
+  port: 8080 # http port
+
+# Allowed CORS origins.
+origins:
+  - https://a.example # primary
+  - https://b.example
+
+debug: false # never in prod
+`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	cfg := new(commentedConfig)
+	require.NoError(t, New(cfg,
+		WithYAMLRoundTrip[commentedConfig](),
+		WithEncoderOptions[commentedConfig](EncoderOptions{YAMLComments: true}),
+		WithOnLoad(func(c *commentedConfig) error {
+			c.Server.Port = 9090
+			c.Origins = append(c.Origins, "https://c.example")
+			c.Level = "info"
+			return nil
+		}),
+		WithSyncingConfigToFiles[commentedConfig](),
+	))
+
+	// comments are kept, blank lines between top-level keys are not tracked by yaml.v3
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	assert.Equal(t, `# Service configuration.
+# Edited by hand.
+
+server:
+  # public interface
+  host: 0.0.0.0
+  port: 9090 # http port
+# Allowed CORS origins.
+origins:
+  - https://a.example # primary
+  - https://b.example
+  - https://c.example
+debug: false # never in prod
+# Log level
+level: info
+`, string(data))
+}
+
+func TestWithYAMLRoundTripDeletedKeys(t *testing.T) {
+	type limitsConfig struct {
+		Limits map[string]int `yaml:"limits"`
+		Name   string         `yaml:"name"`
+	}
+
+	fpath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(fpath, []byte(`limits:
+  a: 1 # first
+  b: 2
+name: app
+extra: unknown
+`), 0o600))
+	require.NoError(t, os.Unsetenv(DirEnvName))
+	t.Setenv(FilePathEnvName, fpath)
+
+	cfg := new(limitsConfig)
+	require.NoError(t, New(cfg,
+		WithYAMLRoundTrip[limitsConfig](),
+		WithOnLoad(func(c *limitsConfig) error {
+			delete(c.Limits, "b")
+			return nil
+		}),
+		WithSyncingConfigToFiles[limitsConfig](),
+	))
+
+	// entries deleted from maps are removed, unknown keys of structs are kept
+	data, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	assert.Equal(t, `limits:
+  a: 1 # first
+name: app
+extra: unknown
+`, string(data))
+
+	loaded := new(limitsConfig)
+	require.NoError(t, New(loaded))
+	assert.Equal(t, map[string]int{"a": 1}, loaded.Limits)
+}